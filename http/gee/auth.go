@@ -0,0 +1,399 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 认证方式，即Principal.Scheme的取值
+const (
+	AuthSchemeBasic  = "basic"
+	AuthSchemeJWT    = "jwt"
+	AuthSchemeAPIKey = "apikey"
+)
+
+// Principal 认证通过的主体，由认证中间件保存在Context上
+type Principal struct {
+	Scheme string
+	// 用户名、JWT的sub或API Key对应的名称
+	Name string
+	// JWT的全部声明，其他认证方式为nil
+	Claims JWTClaims
+}
+
+// Principal 当前请求认证通过的主体，没有经过认证中间件时为nil
+func (c *Context) Principal() *Principal {
+	return c.principal
+}
+
+// Accounts 用户名到密码的映射
+type Accounts map[string]string
+
+// BasicAuth HTTP基本认证，realm为"Authorization Required"
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuthForRealm 认证失败时返回401，并在WWW-Authenticate中带上realm
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	if len(accounts) == 0 {
+		panic("gee: BasicAuth requires at least one account")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	return func(c *Context) {
+		user, password, ok := c.Req.BasicAuth()
+		if ok {
+			// 用户不存在时同样做一次比较，避免通过耗时判断用户是否存在
+			expected, exists := accounts[user]
+			match := subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+			if exists && match {
+				c.principal = &Principal{Scheme: AuthSchemeBasic, Name: user}
+				c.Next()
+				return
+			}
+		}
+		c.SetHeader("WWW-Authenticate", challenge)
+		c.Fail(http.StatusUnauthorized, "Unauthorized")
+	}
+}
+
+// APIKeyConfig API Key认证配置
+type APIKeyConfig struct {
+	// 读取API Key的请求头，默认为X-API-Key
+	Header string
+	// 请求头中没有时读取的查询参数，为空表示不从查询参数读取
+	Query string
+	// API Key到名称的映射，名称作为Principal.Name
+	Keys map[string]string
+	// 自定义校验，返回名称以及是否有效，设置后不再使用Keys
+	Validator func(key string) (string, bool)
+}
+
+// APIKey API Key认证中间件，缺少或无效的API Key返回401
+func APIKey(conf APIKeyConfig) HandlerFunc {
+	if conf.Header == "" {
+		conf.Header = "X-API-Key"
+	}
+	validate := conf.Validator
+	if validate == nil {
+		if len(conf.Keys) == 0 {
+			panic("gee: APIKey requires Keys or a Validator")
+		}
+		validate = func(key string) (string, bool) {
+			found, name := false, ""
+			// 逐个做常量时间比较，不提前返回
+			for k, n := range conf.Keys {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+					found, name = true, n
+				}
+			}
+			return name, found
+		}
+	}
+	return func(c *Context) {
+		key := c.Req.Header.Get(conf.Header)
+		if key == "" && conf.Query != "" {
+			key = c.Query(conf.Query)
+		}
+		if key == "" {
+			c.Fail(http.StatusUnauthorized, "missing API key")
+			return
+		}
+		name, ok := validate(key)
+		if !ok {
+			c.Fail(http.StatusUnauthorized, "invalid API key")
+			return
+		}
+		c.principal = &Principal{Scheme: AuthSchemeAPIKey, Name: name}
+		c.Next()
+	}
+}
+
+// JWTClaims JWT的声明，数字按JSON解码为float64
+type JWTClaims map[string]interface{}
+
+// Subject 返回sub声明
+func (cl JWTClaims) Subject() string {
+	s, _ := cl["sub"].(string)
+	return s
+}
+
+// time 读取exp、nbf、iat等以秒为单位的时间声明
+func (cl JWTClaims) time(name string) (time.Time, bool, error) {
+	v, ok := cl[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return time.Time{}, true, fmt.Errorf("gee: claim %q is not a number", name)
+	}
+	return time.Unix(int64(n), 0), true, nil
+}
+
+// hasAudience aud可以是字符串或字符串数组
+func (cl JWTClaims) hasAudience(audience string) bool {
+	switch aud := cl["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	ErrJWTMalformed = errors.New("gee: malformed token")
+	ErrJWTSignature = errors.New("gee: invalid token signature")
+	ErrJWTExpired   = errors.New("gee: token is expired")
+	ErrJWTNotValid  = errors.New("gee: token is not valid yet")
+)
+
+// JWTConfig JWT认证配置，支持HS256/384/512和RS256/384/512
+type JWTConfig struct {
+	// 按kid查找验证密钥，HMAC算法为[]byte，RSA算法为*rsa.PublicKey
+	// 轮换密钥时同时保留新旧两个kid，令牌中没有kid时使用空字符串对应的密钥
+	Keys map[string]interface{}
+	// 动态获取密钥，例如从JWKS拉取，设置后不再使用Keys
+	KeyFunc func(kid, alg string) (interface{}, error)
+	// 允许的签名算法，默认为密钥类型对应的全部算法，防止用公钥冒充HMAC密钥
+	Algorithms []string
+	// 校验iss和aud，为空时不校验
+	Issuer   string
+	Audience string
+	// 必须存在的声明
+	RequiredClaims []string
+	// 校验exp、nbf时允许的时钟偏差
+	Leeway time.Duration
+	// 请求头中没有Bearer令牌时读取的查询参数，为空表示不从查询参数读取
+	Query string
+	// 声明校验通过后的附加校验
+	Validator func(claims JWTClaims) error
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+// JWT Bearer令牌认证中间件，令牌缺失或无效时返回401
+func JWT(conf JWTConfig) HandlerFunc {
+	if conf.KeyFunc == nil && len(conf.Keys) == 0 {
+		panic("gee: JWT requires Keys or a KeyFunc")
+	}
+	return func(c *Context) {
+		token := ""
+		if auth := c.Req.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+			token = strings.TrimSpace(auth[7:])
+		} else if conf.Query != "" {
+			token = c.Query(conf.Query)
+		}
+		if token == "" {
+			c.SetHeader("WWW-Authenticate", "Bearer")
+			c.Fail(http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		claims, err := conf.Parse(token, time.Now())
+		if err != nil {
+			c.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.Fail(http.StatusUnauthorized, err.Error())
+			return
+		}
+		c.principal = &Principal{Scheme: AuthSchemeJWT, Name: claims.Subject(), Claims: claims}
+		c.Next()
+	}
+}
+
+// Parse 验证令牌签名并校验声明
+func (conf *JWTConfig) Parse(token string, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	hashType, ok := jwtHashes[header.Alg]
+	if !ok || !conf.algorithmAllowed(header.Alg) {
+		return nil, fmt.Errorf("gee: unsupported signing algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	key, err := conf.key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyJWT(header.Alg, hashType, parts[0]+"."+parts[1], signature, key); err != nil {
+		return nil, err
+	}
+	var claims JWTClaims
+	if err = decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err = conf.validateClaims(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (conf *JWTConfig) key(kid, alg string) (interface{}, error) {
+	if conf.KeyFunc != nil {
+		return conf.KeyFunc(kid, alg)
+	}
+	if key, ok := conf.Keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("gee: unknown key id %q", kid)
+}
+
+func (conf *JWTConfig) algorithmAllowed(alg string) bool {
+	if len(conf.Algorithms) == 0 {
+		return true
+	}
+	for _, a := range conf.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func (conf *JWTConfig) validateClaims(claims JWTClaims, now time.Time) error {
+	if exp, ok, err := claims.time("exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(conf.Leeway)) {
+		return ErrJWTExpired
+	}
+	if nbf, ok, err := claims.time("nbf"); err != nil {
+		return err
+	} else if ok && now.Add(conf.Leeway).Before(nbf) {
+		return ErrJWTNotValid
+	}
+	if _, _, err := claims.time("iat"); err != nil {
+		return err
+	}
+	if conf.Issuer != "" && claims["iss"] != conf.Issuer {
+		return fmt.Errorf("gee: invalid issuer %v", claims["iss"])
+	}
+	if conf.Audience != "" && !claims.hasAudience(conf.Audience) {
+		return fmt.Errorf("gee: token is not intended for audience %q", conf.Audience)
+	}
+	for _, name := range conf.RequiredClaims {
+		if _, ok := claims[name]; !ok {
+			return fmt.Errorf("gee: missing required claim %q", name)
+		}
+	}
+	if conf.Validator != nil {
+		return conf.Validator(claims)
+	}
+	return nil
+}
+
+// verifyJWT 密钥类型必须与算法一致，HMAC算法只接受[]byte，RSA算法只接受*rsa.PublicKey
+func verifyJWT(alg string, hashType crypto.Hash, signingInput string, signature []byte, key interface{}) error {
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("gee: key for %s must be []byte", alg)
+		}
+		mac := hmac.New(hashType.New, secret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrJWTSignature
+		}
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("gee: key for %s must be *rsa.PublicKey", alg)
+		}
+		h := hashType.New()
+		h.Write([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(pub, hashType, h.Sum(nil), signature) != nil {
+			return ErrJWTSignature
+		}
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return ErrJWTMalformed
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrJWTMalformed
+	}
+	return nil
+}
+
+// SignJWT 签发令牌，HMAC算法的key为[]byte，RSA算法的key为*rsa.PrivateKey，kid为空时不写入头部
+func SignJWT(claims JWTClaims, alg, kid string, key interface{}) (string, error) {
+	hashType, ok := jwtHashes[alg]
+	if !ok {
+		return "", fmt.Errorf("gee: unsupported signing algorithm %q", alg)
+	}
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return "", fmt.Errorf("gee: key for %s must be []byte", alg)
+		}
+		mac := hmac.New(hashType.New, secret)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case "RS":
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return "", fmt.Errorf("gee: key for %s must be *rsa.PrivateKey", alg)
+		}
+		h := hashType.New()
+		h.Write([]byte(signingInput))
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, priv, hashType, h.Sum(nil)); err != nil {
+			return "", err
+		}
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
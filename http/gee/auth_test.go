@@ -0,0 +1,122 @@
+package gee
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func principalName(c *Context) {
+	p := c.Principal()
+	c.String(http.StatusOK, "%s:%s", p.Scheme, p.Name)
+}
+
+func TestBasicAuth(t *testing.T) {
+	r := New()
+	r.Use(BasicAuth(Accounts{"admin": "secret"}))
+	r.GET("/admin", principalName)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "basic:admin" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	req.SetBasicAuth("admin", "wrong")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Basic realm="Authorization Required"` {
+		t.Fatalf("wrong password should get 401, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	r := New()
+	r.Use(APIKey(APIKeyConfig{Query: "api_key", Keys: map[string]string{"k-123": "billing"}}))
+	r.GET("/data", principalName)
+
+	if w := performRequest(r, http.MethodGet, "/data", withHeader("X-Api-Key", "k-123")); w.Body.String() != "apikey:billing" {
+		t.Fatalf("unexpected header key response %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(r, http.MethodGet, "/data?api_key=k-123"); w.Body.String() != "apikey:billing" {
+		t.Fatalf("unexpected query key response %d %q", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/data", "/data?api_key=bad"} {
+		if w := performRequest(r, http.MethodGet, path); w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s should get 401, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestJWT(t *testing.T) {
+	oldSecret, newSecret := []byte("old-secret"), []byte("new-secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.Use(JWT(JWTConfig{
+		// 轮换期间新旧密钥同时有效
+		Keys: map[string]interface{}{
+			"2023": oldSecret,
+			"2024": newSecret,
+			"rsa":  &rsaKey.PublicKey,
+		},
+		Issuer:         "gee",
+		Audience:       "api",
+		RequiredClaims: []string{"sub"},
+	}))
+	r.GET("/me", func(c *Context) {
+		c.String(http.StatusOK, "%s %v", c.Principal().Name, c.Principal().Claims["role"])
+	})
+
+	now := time.Now()
+	valid := JWTClaims{"sub": "alice", "iss": "gee", "aud": []string{"web", "api"}, "role": "admin",
+		"exp": now.Add(time.Hour).Unix(), "iat": now.Unix()}
+	bearer := func(token string) requestOption { return withHeader("Authorization", "Bearer "+token) }
+	sign := func(claims JWTClaims, alg, kid string, key interface{}) string {
+		token, err := SignJWT(claims, alg, kid, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	for _, token := range []string{
+		sign(valid, "HS256", "2023", oldSecret),
+		sign(valid, "HS512", "2024", newSecret),
+		sign(valid, "RS256", "rsa", rsaKey),
+	} {
+		if w := performRequest(r, http.MethodGet, "/me", bearer(token)); w.Code != http.StatusOK || w.Body.String() != "alice admin" {
+			t.Fatalf("valid token rejected: %d %q", w.Code, w.Body.String())
+		}
+	}
+
+	expired := JWTClaims{"sub": "alice", "iss": "gee", "aud": "api", "exp": now.Add(-time.Hour).Unix()}
+	wrongAud := JWTClaims{"sub": "alice", "iss": "gee", "aud": "other"}
+	noSub := JWTClaims{"iss": "gee", "aud": "api"}
+	invalid := map[string]string{
+		"expired":        sign(expired, "HS256", "2024", newSecret),
+		"wrong audience": sign(wrongAud, "HS256", "2024", newSecret),
+		"missing sub":    sign(noSub, "HS256", "2024", newSecret),
+		"wrong secret":   sign(valid, "HS256", "2024", oldSecret),
+		"unknown kid":    sign(valid, "HS256", "2022", oldSecret),
+		// 用RSA公钥作为HMAC密钥伪造签名
+		"key confusion": sign(valid, "HS256", "rsa", []byte("public key bytes")),
+		"malformed":     "not.a.token",
+	}
+	for name, token := range invalid {
+		w := performRequest(r, http.MethodGet, "/me", bearer(token))
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer error="invalid_token"` {
+			t.Fatalf("%s: expected 401, got %d %v", name, w.Code, w.Header())
+		}
+	}
+	if w := performRequest(r, http.MethodGet, "/me"); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Fatalf("missing token: expected 401, got %d %v", w.Code, w.Header())
+	}
+}
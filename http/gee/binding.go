@@ -0,0 +1,423 @@
+package gee
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// defaultMultipartMemory 解析multipart表单时保存在内存中的最大字节数，超出部分写入临时文件
+const defaultMultipartMemory = 32 << 20
+
+// Binding 从请求中解码数据到结构体，解码后统一经过binding标签校验
+type Binding interface {
+	Name() string
+	Bind(req *http.Request, obj interface{}) error
+}
+
+var (
+	BindingJSON          Binding = jsonBinding{}
+	BindingXML           Binding = xmlBinding{}
+	BindingForm          Binding = formBinding{}
+	BindingFormMultipart Binding = multipartBinding{}
+	BindingQuery         Binding = queryBinding{}
+	BindingHeader        Binding = headerBinding{}
+)
+
+// bindingFor 按请求方法和Content-Type选择解码方式
+func bindingFor(method, contentType string) Binding {
+	if method == http.MethodGet || method == http.MethodHead {
+		return BindingForm
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case MIMEJSON:
+		return BindingJSON
+	case MIMEXML, MIMEXML2:
+		return BindingXML
+	case "multipart/form-data":
+		return BindingFormMultipart
+	default:
+		return BindingForm
+	}
+}
+
+// ShouldBind 按Content-Type自动选择解码方式，出错时只返回错误，由调用方决定如何响应
+func (c *Context) ShouldBind(obj interface{}) error {
+	return c.ShouldBindWith(obj, bindingFor(c.Method, c.Req.Header.Get("Content-Type")))
+}
+
+// ShouldBindWith 使用指定的解码方式绑定并校验
+func (c *Context) ShouldBindWith(obj interface{}, b Binding) error {
+	if b == BindingFormMultipart && c.Req.MultipartForm == nil {
+		// 按Engine.MaxMultipartMemory预先解析，绑定时不会再次解析
+		if err := c.Req.ParseMultipartForm(c.multipartMemory()); err != nil {
+			return err
+		}
+	}
+	return b.Bind(c.Req, obj)
+}
+
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingJSON)
+}
+
+func (c *Context) ShouldBindXML(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingXML)
+}
+
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingQuery)
+}
+
+func (c *Context) ShouldBindHeader(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingHeader)
+}
+
+// ShouldBindURI 使用路由中的路径参数绑定，字段通过uri标签对应参数名
+func (c *Context) ShouldBindURI(obj interface{}) error {
+	values := make(map[string][]string, len(c.Params))
+	for _, p := range c.Params {
+		values[p.Key] = []string{p.Value}
+	}
+	if err := mapForm(obj, values, nil, "uri"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+// Bind 与ShouldBind相同，出错时直接以400响应并中止处理链
+func (c *Context) Bind(obj interface{}) error {
+	return c.mustBind(c.ShouldBind(obj))
+}
+
+func (c *Context) BindWith(obj interface{}, b Binding) error {
+	return c.mustBind(c.ShouldBindWith(obj, b))
+}
+
+func (c *Context) BindJSON(obj interface{}) error {
+	return c.mustBind(c.ShouldBindJSON(obj))
+}
+
+func (c *Context) BindXML(obj interface{}) error {
+	return c.mustBind(c.ShouldBindXML(obj))
+}
+
+func (c *Context) BindQuery(obj interface{}) error {
+	return c.mustBind(c.ShouldBindQuery(obj))
+}
+
+func (c *Context) BindHeader(obj interface{}) error {
+	return c.mustBind(c.ShouldBindHeader(obj))
+}
+
+func (c *Context) BindURI(obj interface{}) error {
+	return c.mustBind(c.ShouldBindURI(obj))
+}
+
+// mustBind 绑定失败时返回400，校验错误会逐个字段列在errors中
+func (c *Context) mustBind(err error) error {
+	if err == nil {
+		return nil
+	}
+	c.Abort()
+	body := H{"message": err.Error()}
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		body["errors"] = ve
+	}
+	// 请求体超出MaxBodyBytes的限制
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, body)
+		return err
+	}
+	c.JSON(http.StatusBadRequest, body)
+	return err
+}
+
+type jsonBinding struct{}
+
+func (jsonBinding) Name() string {
+	return "json"
+}
+
+func (jsonBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errors.New("gee: invalid request body")
+	}
+	if err := json.NewDecoder(req.Body).Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type xmlBinding struct{}
+
+func (xmlBinding) Name() string {
+	return "xml"
+}
+
+func (xmlBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return errors.New("gee: invalid request body")
+	}
+	if err := xml.NewDecoder(req.Body).Decode(obj); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type formBinding struct{}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+func (formBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+	if err := mapForm(obj, req.Form, nil, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type multipartBinding struct{}
+
+func (multipartBinding) Name() string {
+	return "multipart/form-data"
+}
+
+func (multipartBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil {
+		return err
+	}
+	if err := mapForm(obj, req.Form, req.MultipartForm.File, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type queryBinding struct{}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+func (queryBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := mapForm(obj, req.URL.Query(), nil, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+type headerBinding struct{}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+func (headerBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := mapForm(obj, req.Header, nil, "header"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+)
+
+// mapForm 按tag把values中的值写入obj指向的结构体，未标注tag的字段以字段名匹配
+// 未标注tag的嵌套结构体会被展开，files为multipart上传的文件
+func mapForm(obj interface{}, values map[string][]string, files map[string][]*multipart.FileHeader, tag string) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("gee: binding target must be a non-nil pointer to struct")
+	}
+	return mapStruct(rv.Elem(), values, files, tag)
+}
+
+func mapStruct(rv reflect.Value, values map[string][]string, files map[string][]*multipart.FileHeader, tag string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		name := sf.Tag.Get(tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if st := indirectType(sf.Type); st.Kind() == reflect.Struct && st != timeType {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						fv.Set(reflect.New(st))
+					}
+					fv = fv.Elem()
+				}
+				if err := mapStruct(fv, values, files, tag); err != nil {
+					return err
+				}
+				continue
+			}
+			name = sf.Name
+		}
+		if tag == "header" {
+			name = textproto.CanonicalMIMEHeaderKey(name)
+		}
+		if fhs := files[name]; len(fhs) > 0 {
+			if err := setFiles(fv, fhs); err != nil {
+				return fmt.Errorf("gee: binding field %s: %w", sf.Name, err)
+			}
+			continue
+		}
+		vals := values[name]
+		if len(vals) == 0 {
+			continue
+		}
+		if err := setField(fv, sf, vals); err != nil {
+			return fmt.Errorf("gee: binding field %s: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+func setFiles(fv reflect.Value, fhs []*multipart.FileHeader) error {
+	switch {
+	case fv.Type() == fileHeaderType:
+		fv.Set(reflect.ValueOf(fhs[0]))
+	case fv.Kind() == reflect.Slice && fv.Type().Elem() == fileHeaderType:
+		fv.Set(reflect.ValueOf(fhs))
+	default:
+		return fmt.Errorf("unsupported type %s for uploaded file", fv.Type())
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, sf reflect.StructField, vals []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		if _, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return setValue(fv, sf, vals[0])
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setValue(slice.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(vals) != fv.Len() {
+			return fmt.Errorf("%q is not valid value for %s", vals, fv.Type())
+		}
+		for i, s := range vals {
+			if err := setValue(fv.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Ptr:
+		v := reflect.New(fv.Type().Elem())
+		if err := setField(v.Elem(), sf, vals); err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	}
+	return setValue(fv, sf, vals[0])
+}
+
+func setValue(v reflect.Value, sf reflect.StructField, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && v.Type() != timeType {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), sf, s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+	// 非字符串类型的空值保持零值
+	if s == "" {
+		return nil
+	}
+	if v.Type() == timeType {
+		layout := sf.Tag.Get("time_format")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
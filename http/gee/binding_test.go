@@ -0,0 +1,124 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type signupForm struct {
+	Name     string    `form:"name" json:"name" binding:"required,min=3,max=10"`
+	Email    string    `form:"email" json:"email" binding:"required,regexp=^[^@]+@[^@]+$"`
+	Age      int       `form:"age" json:"age" binding:"omitempty,min=18"`
+	Role     string    `form:"role" json:"role" binding:"oneof=admin user"`
+	Tags     []string  `form:"tag" json:"tags" binding:"max=2"`
+	Birthday time.Time `form:"birthday" time_format:"2006-01-02" json:"-"`
+}
+
+func TestShouldBindByContentType(t *testing.T) {
+	body := `{"name":"gopher","email":"go@pher.dev","role":"user","tags":["a"]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	c := &Context{Req: req, Method: req.Method}
+	var form signupForm
+	if err := c.ShouldBind(&form); err != nil || form.Name != "gopher" || form.Tags[0] != "a" {
+		t.Fatalf("bind json failed: %v %+v", err, form)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?name=gopher&email=a@b&role=admin&tag=x&tag=y&age=20&birthday=2019-08-17", nil)
+	c = &Context{Req: req, Method: req.Method}
+	form = signupForm{}
+	if err := c.ShouldBind(&form); err != nil || form.Age != 20 || len(form.Tags) != 2 || form.Birthday.Day() != 17 {
+		t.Fatalf("bind query failed: %v %+v", err, form)
+	}
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	_ = mw.WriteField("name", "gopher")
+	_ = mw.WriteField("email", "a@b")
+	_ = mw.WriteField("role", "user")
+	fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+	_, _ = fw.Write([]byte("png"))
+	_ = mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/", buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c = &Context{Req: req, Method: req.Method}
+	var upload struct {
+		Form   signupForm
+		Avatar *multipart.FileHeader `form:"avatar" binding:"required"`
+	}
+	if err := c.ShouldBind(&upload); err != nil || upload.Form.Name != "gopher" || upload.Avatar.Filename != "avatar.png" {
+		t.Fatalf("bind multipart failed: %v %+v", err, upload)
+	}
+}
+
+func TestBindURIAndHeader(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Context) {
+		var uri struct {
+			ID int `uri:"id" binding:"required,min=1"`
+		}
+		var header struct {
+			Version string `header:"Accept-Version" binding:"required"`
+		}
+		if c.BindURI(&uri) != nil || c.BindHeader(&header) != nil {
+			return
+		}
+		c.String(http.StatusOK, "%d %s", uri.ID, header.Version)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set("Accept-Version", "v2")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "7 v2" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+
+	if w = performRequest(r, http.MethodGet, "/users/0"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400, got %d", w.Code)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	r := New()
+	r.POST("/signup", func(c *Context) {
+		var form signupForm
+		if c.Bind(&form) != nil {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	req := httptest.NewRequest(http.MethodPost, "/signup",
+		strings.NewReader(`{"name":"go","email":"bad","age":3,"role":"root","tags":["a","b","c"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if w.Code != http.StatusBadRequest || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+		t.Fatalf("expect 400 with json body, got %d %s", w.Code, w.Body.String())
+	}
+	expect := []string{"Name:min", "Email:regexp", "Age:min", "Role:oneof", "Tags:max"}
+	if len(body.Errors) != len(expect) {
+		t.Fatalf("expect %d field errors, got %+v", len(expect), body.Errors)
+	}
+	for i, e := range body.Errors {
+		if e.Field+":"+e.Tag != expect[i] {
+			t.Fatalf("field error %d expect %s, got %+v", i, expect[i], e)
+		}
+	}
+
+	var missing signupForm
+	err := validate(&missing)
+	if ve, ok := err.(ValidationErrors); !ok || len(ve) != 3 || ve[0].Message != "Name is required" {
+		t.Fatalf("unexpected required errors %v", err)
+	}
+}
@@ -0,0 +1,382 @@
+package gee
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingBrotli  = "br"
+)
+
+// CompressConfig 响应压缩配置，零值即为默认配置
+type CompressConfig struct {
+	// 压缩级别，0表示各算法的默认级别
+	Level int
+	// 响应体不小于该字节数才压缩，默认1024，流式响应在Flush时总会压缩
+	MinLength int
+	// 服务端支持并优先使用的编码，默认依次为br、gzip、deflate
+	Encodings []string
+	// 不压缩的路径前缀
+	ExcludedPaths []string
+	// 不压缩的文件扩展名，如".png"
+	ExcludedExtensions []string
+	// 不压缩的Content-Type前缀，默认排除图片、音视频和压缩包等已压缩的格式
+	ExcludedContentTypes []string
+	// 解压后的请求体最大字节数，默认32MB，超出时读取报错，绑定函数返回413；负数表示不限制
+	MaxDecompressedBytes int64
+}
+
+var defaultExcludedContentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "video/", "audio/",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-brotli",
+	"application/x-7z-compressed", "application/x-rar-compressed", "font/woff",
+}
+
+const defaultMaxDecompressedBytes = 32 << 20
+
+// compressors 按编码和级别复用的压缩器
+var compressors sync.Map
+
+type resetWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func newCompressor(encoding string, level int, w io.Writer) (resetWriter, error) {
+	key := encoding + strconv.Itoa(level)
+	pool, _ := compressors.LoadOrStore(key, &sync.Pool{})
+	if cw, ok := pool.(*sync.Pool).Get().(resetWriter); ok {
+		cw.Reset(w)
+		return cw, nil
+	}
+	switch encoding {
+	case EncodingBrotli:
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		if level < brotli.BestSpeed || level > brotli.BestCompression {
+			return nil, fmt.Errorf("gee: invalid brotli compression level %d", level)
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	case EncodingDeflate:
+		if level == 0 {
+			level = zlib.DefaultCompression
+		}
+		return zlib.NewWriterLevel(w, level)
+	case EncodingGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+	return nil, fmt.Errorf("gee: unsupported content encoding %q", encoding)
+}
+
+func releaseCompressor(encoding string, level int, cw resetWriter) {
+	if pool, ok := compressors.Load(encoding + strconv.Itoa(level)); ok {
+		pool.(*sync.Pool).Put(cw)
+	}
+}
+
+// Compress 按Accept-Encoding压缩响应，同时解压Content-Encoding为gzip或deflate的请求体
+func Compress(conf CompressConfig) HandlerFunc {
+	if conf.MinLength <= 0 {
+		conf.MinLength = 1024
+	}
+	if len(conf.Encodings) == 0 {
+		conf.Encodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	}
+	if conf.ExcludedContentTypes == nil {
+		conf.ExcludedContentTypes = defaultExcludedContentTypes
+	}
+	if conf.MaxDecompressedBytes == 0 {
+		conf.MaxDecompressedBytes = defaultMaxDecompressedBytes
+	}
+	// 提前检查编码和压缩级别，避免到处理请求时才出错
+	for _, enc := range conf.Encodings {
+		cw, err := newCompressor(enc, conf.Level, io.Discard)
+		if err != nil {
+			panic(err.Error())
+		}
+		releaseCompressor(enc, conf.Level, cw)
+	}
+	return func(c *Context) {
+		if !decompressRequest(c, conf.MaxDecompressedBytes) {
+			return
+		}
+		for _, prefix := range conf.ExcludedPaths {
+			if strings.HasPrefix(c.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		ext := path.Ext(c.Path)
+		for _, excluded := range conf.ExcludedExtensions {
+			if ext != "" && strings.EqualFold(ext, excluded) {
+				c.Next()
+				return
+			}
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.Req.Header.Get("Accept-Encoding"), conf.Encodings)
+		if encoding == "" || c.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: c.Writer, conf: &conf, encoding: encoding}
+		c.Writer = cw
+		completed := false
+		defer func() {
+			if !completed {
+				// 处理链panic时丢弃尚未发出的数据，外层的Recovery仍可以写出500
+				cw.buf = nil
+			}
+			cw.close()
+			c.Writer = cw.ResponseWriter
+		}()
+		c.Next()
+		completed = true
+	}
+}
+
+// decompressRequest 解压请求体，格式错误时返回400并中止
+// MaxBodyBytes只能限制压缩后的大小，解压后的大小由limit限制，防止压缩炸弹耗尽内存
+func decompressRequest(c *Context, limit int64) bool {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return true
+	}
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	switch strings.ToLower(strings.TrimSpace(c.Req.Header.Get("Content-Encoding"))) {
+	case EncodingGzip, "x-gzip":
+		r, err = gzip.NewReader(c.Req.Body)
+	case EncodingDeflate:
+		r, err = zlib.NewReader(c.Req.Body)
+	default:
+		return true
+	}
+	if err != nil {
+		c.Fail(http.StatusBadRequest, "invalid "+c.Req.Header.Get("Content-Encoding")+" request body")
+		return false
+	}
+	var body io.ReadCloser = &decompressedBody{Reader: r, decoder: r, body: c.Req.Body}
+	if limit > 0 {
+		body = http.MaxBytesReader(c.Writer, body, limit)
+	}
+	c.Req.Body = body
+	c.Req.Header.Del("Content-Encoding")
+	c.Req.Header.Del("Content-Length")
+	c.Req.ContentLength = -1
+	return true
+}
+
+type decompressedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	b.decoder.Close()
+	return b.body.Close()
+}
+
+// negotiateEncoding 在客户端可接受(q>0)的编码中按服务端的优先顺序选择
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[name] = q
+	}
+	candidates := make([]string, 0, len(supported))
+	for _, enc := range supported {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > 0 {
+			candidates = append(candidates, enc)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	// q值相同时保持服务端的优先顺序
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return qOf(accepted, candidates[i]) > qOf(accepted, candidates[j])
+	})
+	return candidates[0]
+}
+
+func qOf(accepted map[string]float64, enc string) float64 {
+	if q, ok := accepted[enc]; ok {
+		return q
+	}
+	return accepted["*"]
+}
+
+// compressWriter 先缓冲响应体，达到MinLength或Flush时才决定是否压缩
+type compressWriter struct {
+	ResponseWriter
+	conf     *CompressConfig
+	encoding string
+	buf      []byte
+	// 已决定是否压缩
+	decided bool
+	cw      resetWriter
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if len(w.buf) < w.conf.MinLength {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// decide 决定是否压缩并写出已缓冲的数据，compress为false时按原样输出
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// 必须在压缩前根据原始数据确定类型，否则net/http会对压缩后的数据做嗅探
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && w.shouldCompress() {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		// 编码和级别已在创建中间件时检查过
+		w.cw, _ = newCompressor(w.encoding, w.conf.Level, w.ResponseWriter)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	header := w.Header()
+	status := w.Status()
+	// 处理函数已自行编码(如预压缩的静态文件)、范围请求或不带响应体的状态码都不压缩
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		status == http.StatusPartialContent || !bodyAllowedForStatus(status) {
+		return false
+	}
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	for _, excluded := range w.conf.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush 流式响应不等待MinLength，直接开始压缩并把已压缩的数据推给客户端
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// WriteHeaderNow 响应头发出后不能再加Content-Encoding，因此先按原样输出已缓冲的数据
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// WriteHeader 缓冲中已有数据后不能再修改状态码，但数据尚未发出时错误状态码会丢弃缓冲，
+// 以便Recovery等把写了一半的响应改写为错误响应
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided && len(w.buf) > 0 {
+		if code < http.StatusBadRequest {
+			return
+		}
+		w.buf = nil
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Written 与底层一致，只有响应头真正发出后才算写入
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// close 处理链结束时调用，响应体不足MinLength的按原样输出
+func (w *compressWriter) close() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+		releaseCompressor(w.encoding, w.conf.Level, w.cw)
+		w.cw = nil
+	}
+}
@@ -0,0 +1,254 @@
+package gee
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	cases := map[string]string{
+		"":                       "",
+		"gzip, deflate, br":      EncodingBrotli,
+		"gzip;q=1.0, br;q=0.5":   EncodingGzip,
+		"br;q=0, *":              EncodingGzip,
+		"identity":               "",
+		"deflate, gzip;q=0.8":    EncodingDeflate,
+		"GZIP":                   EncodingGzip,
+		"*;q=0.1, deflate;q=0.2": EncodingDeflate,
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header, supported); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("gee compress ", 200)
+	r := New()
+	r.Use(Compress(CompressConfig{ExcludedPaths: []string{"/raw"}}))
+	r.GET("/large", func(c *Context) {
+		c.SetHeader("Content-Length", "2600")
+		c.SetHeader("ETag", `"v1"`)
+		c.String(http.StatusOK, large)
+	})
+	r.GET("/small", func(c *Context) { c.String(http.StatusOK, "small") })
+	r.GET("/raw/large", func(c *Context) { c.String(http.StatusOK, large) })
+	r.GET("/png", func(c *Context) {
+		c.SetHeader("Content-Type", "image/png")
+		c.Data(http.StatusOK, []byte(large))
+	})
+
+	w := performRequest(r, http.MethodGet, "/large", withHeader("Accept-Encoding", "gzip"))
+	h := w.Header()
+	if h.Get("Content-Encoding") != "gzip" || h.Get("Content-Length") != "" ||
+		h.Get("Vary") != "Accept-Encoding" || h.Get("ETag") != `W/"v1"` ||
+		!strings.HasPrefix(h.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected headers %v", h)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != large {
+		t.Fatalf("gzip body mismatch")
+	}
+
+	w = performRequest(r, http.MethodGet, "/large", withHeader("Accept-Encoding", "gzip, br"))
+	if w.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("expected br, got %v", w.Header())
+	}
+	if body, _ := io.ReadAll(brotli.NewReader(w.Body)); string(body) != large {
+		t.Fatalf("br body mismatch")
+	}
+
+	// 低于阈值、排除的路径和已压缩的类型都原样输出
+	for path, vary := range map[string]string{"/small": "Accept-Encoding", "/raw/large": "", "/png": "Accept-Encoding"} {
+		w = performRequest(r, http.MethodGet, path, withHeader("Accept-Encoding", "gzip"))
+		if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != vary {
+			t.Fatalf("%s should not be compressed: %v", path, w.Header())
+		}
+	}
+	if w = performRequest(r, http.MethodGet, "/small", withHeader("Accept-Encoding", "gzip")); w.Body.String() != "small" {
+		t.Fatalf("unexpected small body %q", w.Body.String())
+	}
+	// 不支持压缩的客户端
+	if w = performRequest(r, http.MethodGet, "/large", withHeader("Accept-Encoding", "")); w.Body.String() != large || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("client without Accept-Encoding got %v", w.Header())
+	}
+}
+
+func TestCompressStream(t *testing.T) {
+	r := New()
+	r.Use(Compress(CompressConfig{}))
+	r.GET("/stream", func(c *Context) {
+		c.SetHeader("Content-Type", MIMEEventStream)
+		for i := 0; i < 3; i++ {
+			c.Writer.WriteString("data: tick\n\n")
+			c.Writer.Flush()
+		}
+	})
+	w := performRequest(r, http.MethodGet, "/stream", withHeader("Accept-Encoding", "gzip"))
+	if w.Header().Get("Content-Encoding") != "gzip" || !w.Flushed {
+		t.Fatalf("stream should be compressed and flushed: %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != strings.Repeat("data: tick\n\n", 3) {
+		t.Fatalf("unexpected stream body %q", body)
+	}
+}
+
+func TestCompressStatic(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("body { color: red; }\n", 100)
+	if err := os.WriteFile(filepath.Join(dir, "app.css"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.Use(Compress(CompressConfig{}))
+	r.Static("/assets", dir)
+
+	w := performRequest(r, http.MethodGet, "/assets/app.css", withHeader("Accept-Encoding", "gzip"))
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("unexpected static headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != content {
+		t.Fatalf("static body mismatch")
+	}
+
+	// 范围请求不压缩
+	req := httptest.NewRequest(http.MethodGet, "/assets/app.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-3")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "body" {
+		t.Fatalf("unexpected range response %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+}
+
+func TestCompressRequestBody(t *testing.T) {
+	r := New()
+	r.Use(Compress(CompressConfig{}))
+	r.POST("/echo", func(c *Context) {
+		body, _ := io.ReadAll(c.Req.Body)
+		c.Data(http.StatusOK, body)
+	})
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello gzip"))
+	zw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/echo", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "hello gzip" {
+		t.Fatalf("unexpected decompressed body %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid gzip body should get 400, got %d", w.Code)
+	}
+}
+
+func TestCompressDecompressedLimit(t *testing.T) {
+	r := New()
+	r.Use(Compress(CompressConfig{MaxDecompressedBytes: 1024}))
+	r.POST("/upload", func(c *Context) {
+		var tooLarge *http.MaxBytesError
+		if _, err := io.ReadAll(c.Req.Body); errors.As(err, &tooLarge) {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	// 64KB的0压缩后只有几十字节
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(make([]byte, 64<<10))
+	zw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("decompressed body over the limit should be rejected, got %d", w.Code)
+	}
+}
+
+func TestCompressWriteHeaderNow(t *testing.T) {
+	large := strings.Repeat("gee compress ", 200)
+	r := New()
+	r.Use(Compress(CompressConfig{}))
+	r.GET("/header-now", func(c *Context) {
+		c.Writer.WriteString("head ")
+		c.Writer.WriteHeaderNow()
+		c.Writer.WriteString(large)
+	})
+	r.GET("/status", func(c *Context) {
+		c.Writer.WriteString("small")
+		// 缓冲中已有数据，状态码不能再修改
+		c.Status(http.StatusCreated)
+	})
+	r.GET("/error", func(c *Context) {
+		c.Writer.WriteString("small")
+		// 数据尚未发出，错误响应替换已缓冲的数据
+		c.Fail(http.StatusInternalServerError, "failed")
+	})
+
+	// 响应头已经发出，之后的数据不能再压缩
+	w := performRequest(r, http.MethodGet, "/header-now", withHeader("Accept-Encoding", "gzip"))
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "head "+large {
+		t.Fatalf("response after WriteHeaderNow should be sent as is, got %v %q", w.Header(), w.Body.String()[:10])
+	}
+	w = performRequest(r, http.MethodGet, "/status", withHeader("Accept-Encoding", "gzip"))
+	if w.Code != http.StatusOK || w.Body.String() != "small" {
+		t.Fatalf("status should be locked once written, got %d %q", w.Code, w.Body.String())
+	}
+	w = performRequest(r, http.MethodGet, "/error", withHeader("Accept-Encoding", "gzip"))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "{\"message\":\"failed\"}\n" {
+		t.Fatalf("error status should replace the buffered body, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCompressWithRecovery(t *testing.T) {
+	for name, middlewares := range map[string][]HandlerFunc{
+		"compress first": {Compress(CompressConfig{}), RecoveryWithWriter(io.Discard)},
+		"recovery first": {RecoveryWithWriter(io.Discard), Compress(CompressConfig{})},
+	} {
+		r := New()
+		r.Use(middlewares...)
+		r.GET("/panic", func(c *Context) {
+			c.String(http.StatusOK, "partial")
+			panic("boom")
+		})
+		w := performRequest(r, http.MethodGet, "/panic", withHeader("Accept-Encoding", "gzip"))
+		if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "partial") {
+			t.Fatalf("%s: buffered partial body should become a 500, got %d %q", name, w.Code, w.Body.String())
+		}
+	}
+}
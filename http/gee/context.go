@@ -1,64 +1,168 @@
 package gee
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 type H map[string]interface{}
 
+// abortIndex 处理链被中止后index的取值，大于任何合法的处理链长度
+const abortIndex = math.MaxInt32
+
 type Context struct {
-	// origin object
-	Writer http.ResponseWriter
-	Req    *http.Request
+	// origin object，Writer包装了原始的http.ResponseWriter，状态码和写入字节数都从它读取
+	writermem responseWriter
+	Writer    ResponseWriter
+	Req       *http.Request
 	// request info
 	Path   string
 	Method string
-	Params map[string]string
-	// response info
-	StatusCode int
+	Params Params
+	// 匹配到的路由模式，如"/user/:id"，未匹配到路由时为空
+	fullPath string
+	// 认证中间件设置的主体
+	principal *Principal
+	// 中间件与处理函数之间传递数据，读写都需要持有mu
+	mu   sync.RWMutex
+	keys map[string]interface{}
 	// middleware
 	handlers []HandlerFunc
-	index int
+	index    int
 	// engine
 	engine *Engine
 }
 
-func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    req,
-		Path:   req.URL.Path,
-		Method: req.Method,
-		Params: make(map[string]string),
-		index: -1,
-		handlers: make([]HandlerFunc, 0),
+// reset 复用Context前清空上一个请求留下的状态，Params保留底层数组
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Method = req.Method
+	c.Params = c.Params[:0]
+	c.fullPath = ""
+	c.principal = nil
+	c.keys = nil
+	c.handlers = nil
+	c.index = -1
+}
+
+// Copy 返回当前Context的只读快照，可以安全地交给后台goroutine使用
+// 原Context在请求结束后会被回收复用，快照不会受影响；快照不能用于写响应，也不能调用Next
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		writermem: c.writermem,
+		Req:       c.Req,
+		Path:      c.Path,
+		Method:    c.Method,
+		fullPath:  c.fullPath,
+		principal: c.principal,
+		index:     abortIndex,
+		engine:    c.engine,
 	}
+	// 快照只保留状态码等信息，不持有原始的http.ResponseWriter
+	cp.writermem.ResponseWriter = nil
+	cp.Writer = &cp.writermem
+	cp.Params = make(Params, len(c.Params))
+	copy(cp.Params, c.Params)
+	c.mu.RLock()
+	if c.keys != nil {
+		cp.keys = make(map[string]interface{}, len(c.keys))
+		for k, v := range c.keys {
+			cp.keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
 }
 
-func (c *Context) Next()  {
+func (c *Context) Next() {
 	c.index++
-	for ; c.index < len(c.handlers); c.index++{
+	for ; c.index < len(c.handlers); c.index++ {
 		c.handlers[c.index](c)
 	}
 }
+
+// Abort 中止处理链，后续的中间件和处理函数不再执行，已进入的外层中间件仍会继续返回
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted 处理链是否已被中止
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus 写入状态码并中止处理链
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
+	c.Abort()
 	c.JSON(code, H{"message": err})
 }
+
+// FullPath 匹配到的路由模式，如"/user/:id"，未匹配到路由时为空字符串
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
 func (c *Context) Param(key string) string {
-	v, _ := c.Params[key]
-	return v
+	return c.Params.ByName(key)
 }
 func (c *Context) PostForm(key string) string {
 	return c.Req.FormValue(key)
 }
+
+// ClientIP 客户端IP，Engine.ForwardedByClientIP为true且请求来自可信代理时，优先取代理设置的X-Forwarded-For和X-Real-IP
+// X-Forwarded-For从右向左跳过可信代理，取第一个不可信的地址，客户端伪造的最左侧地址不会被采用
+func (c *Context) ClientIP() string {
+	remote := c.RemoteIP()
+	if c.engine == nil || !c.engine.ForwardedByClientIP || !c.engine.isTrustedProxy(remote) {
+		return remote
+	}
+	if forwarded := c.Req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(hops[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			if i == 0 || !c.engine.isTrustedProxy(ip) {
+				return ip
+			}
+		}
+	}
+	if ip := strings.TrimSpace(c.Req.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return remote
+}
+
+// RemoteIP 直接与服务器建立连接的IP
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return c.Req.RemoteAddr
+	}
+	return ip
+}
+
 func (c *Context) Query(key string) string {
 	return c.Req.URL.Query().Get(key)
 }
+
+// Status 设置状态码，响应头在第一次写入响应体时才发出
 func (c *Context) Status(code int) {
-	c.StatusCode = code
 	c.Writer.WriteHeader(code)
 }
 
@@ -67,19 +171,11 @@ func (c *Context) SetHeader(key, value string) {
 }
 
 func (c *Context) String(code int, format string, values ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-	c.Writer.Write([]byte(fmt.Sprintf(format, values...)))
+	c.Render(code, textRender{format: format, values: values})
 }
 
 func (c *Context) JSON(code int, obj interface{}) {
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-	encoder := json.NewEncoder(c.Writer)
-	err := encoder.Encode(obj)
-	if err != nil {
-		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
-	}
+	c.Render(code, jsonRender{obj})
 }
 
 func (c *Context) Data(code int, data []byte) {
@@ -87,11 +183,151 @@ func (c *Context) Data(code int, data []byte) {
 	c.Writer.Write(data)
 }
 
+// HTML 模板先渲染到缓冲区，渲染出错时不会留下写了一半的响应
 func (c *Context) HTML(code int, name string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil{
-		c.Fail(500, err.Error())
+	c.Render(code, c.engine.htmlInstance(name, data))
+}
+
+// Set 保存一个键值对，供后续的中间件和处理函数读取
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
 	}
+	c.keys[key] = value
+	c.mu.Unlock()
+}
+
+// Get 读取Set保存的值以及该键是否存在
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	value, exists = c.keys[key]
+	c.mu.RUnlock()
+	return
+}
+
+// MustGet 读取Set保存的值，键不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic(fmt.Sprintf("gee: key %q does not exist", key))
+}
+
+// 以下typed getter在键不存在或类型不符时返回零值
+
+func (c *Context) GetString(key string) (s string) {
+	if v, ok := c.Get(key); ok {
+		s, _ = v.(string)
+	}
+	return
+}
 
+func (c *Context) GetBool(key string) (b bool) {
+	if v, ok := c.Get(key); ok {
+		b, _ = v.(bool)
+	}
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	if v, ok := c.Get(key); ok {
+		i, _ = v.(int)
+	}
+	return
+}
+
+func (c *Context) GetInt64(key string) (i int64) {
+	if v, ok := c.Get(key); ok {
+		i, _ = v.(int64)
+	}
+	return
+}
+
+func (c *Context) GetUint(key string) (u uint) {
+	if v, ok := c.Get(key); ok {
+		u, _ = v.(uint)
+	}
+	return
+}
+
+func (c *Context) GetFloat64(key string) (f float64) {
+	if v, ok := c.Get(key); ok {
+		f, _ = v.(float64)
+	}
+	return
+}
+
+func (c *Context) GetTime(key string) (t time.Time) {
+	if v, ok := c.Get(key); ok {
+		t, _ = v.(time.Time)
+	}
+	return
+}
+
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if v, ok := c.Get(key); ok {
+		d, _ = v.(time.Duration)
+	}
+	return
+}
+
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if v, ok := c.Get(key); ok {
+		ss, _ = v.([]string)
+	}
+	return
+}
+
+func (c *Context) GetStringMap(key string) (sm map[string]interface{}) {
+	if v, ok := c.Get(key); ok {
+		sm, _ = v.(map[string]interface{})
+	}
+	return
+}
+
+func (c *Context) GetStringMapString(key string) (sm map[string]string) {
+	if v, ok := c.Get(key); ok {
+		sm, _ = v.(map[string]string)
+	}
+	return
+}
+
+// Context实现了context.Context，可以直接传给orm、rpc等调用，客户端断开或服务关闭时随请求一起取消
+var _ context.Context = &Context{}
+
+// Deadline 返回请求context的截止时间
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.Req == nil {
+		return
+	}
+	return c.Req.Context().Deadline()
+}
+
+// Done 请求被取消时关闭，没有请求时返回nil，表示永远不会被取消
+func (c *Context) Done() <-chan struct{} {
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Done()
+}
+
+func (c *Context) Err() error {
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Err()
+}
+
+// Value 字符串键优先读取Set保存的值，其余的交给请求context
+func (c *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if v, exists := c.Get(k); exists {
+			return v
+		}
+	}
+	if c.Req == nil {
+		return nil
+	}
+	return c.Req.Context().Value(key)
 }
@@ -0,0 +1,110 @@
+package gee
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestContextKeys(t *testing.T) {
+	r := New()
+	g := r.Group("/set")
+	g.Use(func(c *Context) {
+		c.Set("user", "alice")
+		c.Set("uid", 42)
+		c.Set("admin", true)
+		c.Set("start", time.Unix(100, 0))
+		c.Set("roles", []string{"admin", "dev"})
+		c.Next()
+	})
+	g.GET("/keys", func(c *Context) {
+		if c.GetString("user") != "alice" || c.GetInt("uid") != 42 || !c.GetBool("admin") ||
+			c.GetTime("start").Unix() != 100 || len(c.GetStringSlice("roles")) != 2 {
+			t.Errorf("unexpected typed values")
+		}
+		// 类型不符或不存在时返回零值
+		if c.GetInt("user") != 0 || c.GetString("missing") != "" {
+			t.Errorf("mismatched type should return zero value")
+		}
+		if _, ok := c.Get("missing"); ok {
+			t.Errorf("missing key should not exist")
+		}
+		if c.MustGet("user") != "alice" {
+			t.Errorf("unexpected MustGet result")
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/empty", func(c *Context) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("MustGet of missing key should panic")
+			}
+		}()
+		c.MustGet("user")
+	})
+	performRequest(r, http.MethodGet, "/set/keys")
+	// 池中复用的Context不应带上上一个请求的值
+	performRequest(r, http.MethodGet, "/empty")
+}
+
+func TestContextKeysConcurrent(t *testing.T) {
+	c := &Context{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Set("n", i)
+				c.GetInt("n")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if _, ok := c.Get("n"); !ok {
+		t.Fatal("key should exist")
+	}
+}
+
+type ctxKey struct{}
+
+func TestContextAsContext(t *testing.T) {
+	r := New()
+	done := make(chan error, 1)
+	r.GET("/slow", func(c *Context) {
+		c.Set("trace", "t-1")
+		if c.Value("trace") != "t-1" || c.Value(ctxKey{}) != "from request" {
+			t.Errorf("unexpected values %v %v", c.Value("trace"), c.Value(ctxKey{}))
+		}
+		if _, ok := c.Deadline(); !ok {
+			t.Errorf("deadline should come from the request context")
+		}
+		// 作为context.Context传给下游调用，请求取消时一起取消
+		select {
+		case <-waitFor(c):
+			done <- c.Err()
+		case <-time.After(time.Second):
+			done <- nil
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "from request"), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	var empty Context
+	if empty.Done() != nil || empty.Err() != nil || empty.Value("x") != nil {
+		t.Fatal("context without request should never be cancelled")
+	}
+}
+
+func waitFor(ctx context.Context) <-chan struct{} {
+	return ctx.Done()
+}
@@ -0,0 +1,139 @@
+package gee
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig 跨域资源共享配置
+type CORSConfig struct {
+	// 允许的来源，支持精确匹配、"*"以及"https://*.example.com"形式的通配
+	AllowOrigins []string
+	// 自定义来源判断，与AllowOrigins任一满足即可
+	AllowOriginFunc func(origin string) bool
+	// 预检请求返回的允许方法，默认为常用的全部方法
+	AllowMethods []string
+	// 预检请求返回的允许请求头，包含"*"时原样返回浏览器申请的请求头
+	AllowHeaders []string
+	// 允许浏览器脚本读取的响应头
+	ExposeHeaders []string
+	// 是否允许携带Cookie等凭证，不能与AllowOrigins中的"*"同时使用，需要时列出具体来源或使用AllowOriginFunc
+	AllowCredentials bool
+	// 预检结果的缓存时间，0表示不设置
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+var defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+
+// CORS 跨域中间件，预检请求直接以204应答并中止处理链，不允许的来源返回403
+// 配合Engine.HandleOPTIONS，即使没有注册OPTIONS路由，预检请求也会经过该中间件
+// AllowOrigins包含"*"的同时开启AllowCredentials会让任意网站带凭证访问，此时panic
+func CORS(conf CORSConfig) HandlerFunc {
+	methods := conf.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := conf.AllowHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	reflectHeaders := false
+	for _, h := range headers {
+		if h == "*" {
+			reflectHeaders = true
+		}
+	}
+	exposeHeaders := strings.Join(conf.ExposeHeaders, ", ")
+	allowAll := false
+	for _, o := range conf.AllowOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+	}
+	if allowAll && conf.AllowCredentials {
+		panic(`gee: CORS can not allow credentials for all origins "*"`)
+	}
+	maxAge := ""
+	if conf.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(conf.MaxAge/time.Second), 10)
+	}
+
+	return func(c *Context) {
+		origin := c.Req.Header.Get("Origin")
+		if origin == "" || isSameOrigin(c.Req, origin) {
+			c.Next()
+			return
+		}
+		if !allowAll && !matchOrigin(conf, origin) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		header := c.Writer.Header()
+		if allowAll {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Add("Vary", "Origin")
+		}
+		if conf.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// 预检请求：带有Access-Control-Request-Method的OPTIONS请求
+		if c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			if requested := c.Req.Header.Get("Access-Control-Request-Headers"); reflectHeaders && requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+				header.Add("Vary", "Access-Control-Request-Headers")
+			} else {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if maxAge != "" {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
+		c.Next()
+	}
+}
+
+func matchOrigin(conf CORSConfig, origin string) bool {
+	for _, allowed := range conf.AllowOrigins {
+		if i := strings.IndexByte(allowed, '*'); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return conf.AllowOriginFunc != nil && conf.AllowOriginFunc(origin)
+}
+
+// isSameOrigin 同源请求也可能带Origin头，不需要跨域处理
+func isSameOrigin(req *http.Request, origin string) bool {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return origin == scheme+"://"+req.Host
+}
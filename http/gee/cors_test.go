@@ -0,0 +1,88 @@
+package gee
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// preflight 预检请求携带的请求头
+var preflight = []requestOption{
+	withHeader("Access-Control-Request-Method", http.MethodPut),
+	withHeader("Access-Control-Request-Headers", "X-Token"),
+}
+
+func TestCORS(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.Use(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.preview.example.com"},
+		AllowOriginFunc:  func(origin string) bool { return origin == "http://localhost:3000" },
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	api.PUT("/items/:id", func(c *Context) { c.String(http.StatusOK, "updated") })
+
+	// 没有注册OPTIONS路由，预检请求仍然经过分组的CORS中间件
+	w := performRequest(r, http.MethodOptions, "/api/items/1", append(preflight, withHeader("Origin", "https://pr-1.preview.example.com"))...)
+	h := w.Header()
+	if w.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Origin") != "https://pr-1.preview.example.com" ||
+		h.Get("Access-Control-Allow-Headers") != "X-Token" || h.Get("Access-Control-Max-Age") != "600" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatalf("unexpected preflight response %d %v", w.Code, h)
+	}
+
+	w = performRequest(r, http.MethodPut, "/api/items/1", withHeader("Origin", "http://localhost:3000"))
+	if w.Body.String() != "updated" || w.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+		t.Fatalf("unexpected actual response %q %v", w.Body.String(), w.Header())
+	}
+
+	if w = performRequest(r, http.MethodPut, "/api/items/1", withHeader("Origin", "https://evil.com")); w.Code != http.StatusForbidden {
+		t.Fatalf("disallowed origin should get 403, got %d", w.Code)
+	}
+
+	// 没有CORS中间件的路径，OPTIONS仍会自动应答并带上Allow
+	r.GET("/plain", func(c *Context) {})
+	if w = performRequest(r, http.MethodOptions, "/plain"); w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, OPTIONS" {
+		t.Fatalf("unexpected automatic OPTIONS response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSAllowAll(t *testing.T) {
+	r := New()
+	r.Use(CORS(CORSConfig{AllowOrigins: []string{"*"}}))
+	r.GET("/", func(c *Context) {})
+	w := performRequest(r, http.MethodGet, "/", withHeader("Origin", "https://any.example.com"))
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("unexpected response headers %v", w.Header())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal(`"*" with AllowCredentials should panic`)
+		}
+	}()
+	CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestAutoOptionsDeterministic(t *testing.T) {
+	r := New()
+	items := r.Group("/items")
+	items.Use(func(c *Context) {
+		c.SetHeader("X-Group", "items")
+		c.Next()
+	})
+	items.PUT("/:id", func(c *Context) {})
+	r.DELETE("/:kind/:id", func(c *Context) {})
+	r.POST("/:kind/:id", func(c *Context) {})
+
+	// 三个方法都能匹配，按方法名排序后DELETE在前，使用根分组的处理链
+	for i := 0; i < 50; i++ {
+		w := performRequest(r, http.MethodOptions, "/items/1")
+		if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "DELETE, POST, PUT, OPTIONS" || w.Header().Get("X-Group") != "" {
+			t.Fatalf("unexpected OPTIONS response %d %v", w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package gee
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// MaxBodyBytes 限制请求体大小，声明的Content-Length超出时直接返回413，
+// 未声明长度的请求在读取超出时报错，绑定函数遇到该错误同样返回413
+func MaxBodyBytes(n int64) HandlerFunc {
+	if n <= 0 {
+		panic("gee: MaxBodyBytes requires a positive limit")
+	}
+	return func(c *Context) {
+		if c.Req.ContentLength > n {
+			c.Fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body too large: limit is %d bytes", n))
+			return
+		}
+		if c.Req.Body != nil && c.Req.Body != http.NoBody {
+			c.Req.Body = http.MaxBytesReader(c.Writer, c.Req.Body, n)
+		}
+		c.Next()
+	}
+}
+
+// multipartMemory 解析multipart表单时使用的内存上限
+func (c *Context) multipartMemory() int64 {
+	if c.engine != nil && c.engine.MaxMultipartMemory > 0 {
+		return c.engine.MaxMultipartMemory
+	}
+	return defaultMultipartMemory
+}
+
+// MultipartForm 解析并返回multipart表单，包括上传的文件
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if err := c.Req.ParseMultipartForm(c.multipartMemory()); err != nil {
+		return nil, err
+	}
+	return c.Req.MultipartForm, nil
+}
+
+// FormFile 返回表单中名为name的第一个文件
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.Req.MultipartForm == nil {
+		if err := c.Req.ParseMultipartForm(c.multipartMemory()); err != nil {
+			return nil, err
+		}
+	}
+	f, fh, err := c.Req.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+// SaveUploadedFile 把上传的文件保存到dst，上级目录不存在时自动创建
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// File 发送本地文件，支持Range和If-Modified-Since，文件不存在时返回404
+func (c *Context) File(filepath string) {
+	http.ServeFile(c.Writer, c.Req, filepath)
+}
+
+// FileAttachment 以附件形式发送文件，浏览器会按filename下载而不是直接打开
+func (c *Context) FileAttachment(filepath, filename string) {
+	c.SetHeader("Content-Disposition", contentDisposition("attachment", filename))
+	http.ServeFile(c.Writer, c.Req, filepath)
+}
+
+// FileFromFS 从fs中发送文件，同样支持Range和If-Modified-Since，目录和不存在的文件返回404
+func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
+	f, err := fs.Open(path.Clean("/" + filepath))
+	if err != nil {
+		c.String(http.StatusNotFound, "404 page not found")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.String(http.StatusNotFound, "404 page not found")
+		return
+	}
+	http.ServeContent(c.Writer, c.Req, info.Name(), info.ModTime(), f)
+}
+
+// DataFromReader 从reader流式发送响应体，contentLength为-1表示长度未知
+// extraHeaders中带有Last-Modified时支持If-Modified-Since；状态码为200且reader实现了io.ReadSeeker时还支持Range
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	header := c.Writer.Header()
+	for k, v := range extraHeaders {
+		header.Set(k, v)
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	var modtime time.Time
+	if lm := header.Get("Last-Modified"); lm != "" {
+		modtime, _ = http.ParseTime(lm)
+	}
+
+	if rs, ok := reader.(io.ReadSeeker); ok && code == http.StatusOK {
+		http.ServeContent(c.Writer, c.Req, "", modtime, rs)
+		return
+	}
+	if code == http.StatusOK && notModified(c.Req, modtime) {
+		header.Del("Content-Type")
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if contentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+	c.Status(code)
+	if c.Method == http.MethodHead {
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	_, _ = io.Copy(c.Writer, reader)
+}
+
+// notModified 按If-Modified-Since判断客户端缓存是否仍然有效，精度为秒
+func notModified(req *http.Request, modtime time.Time) bool {
+	if modtime.IsZero() || modtime.Equal(time.Unix(0, 0)) {
+		return false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	ims := req.Header.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !modtime.Truncate(time.Second).After(t)
+}
+
+// contentDisposition 非ASCII文件名使用RFC 5987的filename*参数
+func contentDisposition(kind, filename string) string {
+	for i := 0; i < len(filename); i++ {
+		if filename[i] >= 0x80 {
+			return kind + "; filename*=UTF-8''" + url.PathEscape(filename)
+		}
+	}
+	return kind + "; filename=" + strconv.Quote(filename)
+}
@@ -0,0 +1,158 @@
+package gee
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func multipartRequest(t *testing.T, path, field, filename, content string) *http.Request {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.WriteField("title", "report")
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	r := New()
+	r.MaxMultipartMemory = 8
+	r.POST("/upload", func(c *Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		form, _ := c.MultipartForm()
+		dst := filepath.Join(dir, "nested", file.Filename)
+		if err = c.SaveUploadedFile(file, dst); err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "%s %d", form.Value["title"][0], file.Size)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, multipartRequest(t, "/upload", "file", "a.txt", "hello upload"))
+	if w.Code != http.StatusOK || w.Body.String() != "report 12" {
+		t.Fatalf("unexpected upload response %d %q", w.Code, w.Body.String())
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "nested", "a.txt")); err != nil || string(data) != "hello upload" {
+		t.Fatalf("unexpected saved file %q %v", data, err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, multipartRequest(t, "/upload", "other", "a.txt", "x"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("missing file should get 400, got %d", w.Code)
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	r := New()
+	r.Use(MaxBodyBytes(8))
+	r.POST("/json", func(c *Context) {
+		var obj map[string]interface{}
+		if c.BindJSON(&obj) == nil {
+			c.String(http.StatusOK, "ok")
+		}
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"a":"0123456789"}`)))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("declared length over limit should get 413, got %d", w.Code)
+	}
+
+	// 长度未知的请求体在读取时才超出限制
+	req := httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"a":"0123456789"}`))
+	req.ContentLength = -1
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("streamed body over limit should get 413, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/json", strings.NewReader(`{"a":1}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("small body should pass, got %d", w.Code)
+	}
+}
+
+func TestFileResponses(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(name, []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	os.Chtimes(name, modtime, modtime)
+
+	r := New()
+	r.GET("/file", func(c *Context) { c.File(name) })
+	r.GET("/attachment", func(c *Context) { c.FileAttachment(name, "报告.txt") })
+	r.GET("/fs/*filePath", func(c *Context) { c.FileFromFS(c.Param("filePath"), http.Dir(dir)) })
+	r.GET("/reader", func(c *Context) {
+		c.DataFromReader(http.StatusOK, 5, "text/plain", strings.NewReader("hello"),
+			map[string]string{"Last-Modified": modtime.Format(http.TimeFormat)})
+	})
+	r.GET("/stream", func(c *Context) {
+		// 不支持Seek的reader按长度直接流式输出
+		c.DataFromReader(http.StatusOK, -1, "text/plain", bytes.NewBufferString("streamed"), nil)
+	})
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/file"); w.Body.String() != "0123456789" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("unexpected file response %d %q", w.Code, w.Body.String())
+	}
+	if w := get("/file", "Range", "bytes=2-4"); w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Fatalf("unexpected range response %d %q", w.Code, w.Body.String())
+	}
+	if w := get("/file", "If-Modified-Since", modtime.Format(http.TimeFormat)); w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+	if w := get("/attachment"); w.Header().Get("Content-Disposition") != "attachment; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt" {
+		t.Fatalf("unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+	}
+	if w := get("/fs/data.txt", "Range", "bytes=-3"); w.Body.String() != "789" {
+		t.Fatalf("unexpected FileFromFS response %d %q", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/fs/missing.txt", "/fs/"} {
+		if w := get(path); w.Code != http.StatusNotFound {
+			t.Fatalf("%s should get 404, got %d", path, w.Code)
+		}
+	}
+	if w := get("/reader", "Range", "bytes=1-2"); w.Code != http.StatusPartialContent || w.Body.String() != "el" {
+		t.Fatalf("unexpected reader range response %d %q", w.Code, w.Body.String())
+	}
+	if w := get("/reader", "If-Modified-Since", modtime.Add(time.Hour).Format(http.TimeFormat)); w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for reader, got %d", w.Code)
+	}
+	if w := get("/stream"); w.Body.String() != "streamed" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("unexpected stream response %d %q", w.Code, w.Body.String())
+	}
+}
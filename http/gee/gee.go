@@ -1,10 +1,16 @@
 package gee
 
 import (
+	"fmt"
 	"html/template"
+	"net"
 	"net/http"
-	"path"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 type HandlerFunc func(ctx *Context)
@@ -14,48 +20,300 @@ type RouterGroup struct {
 	middlewares []HandlerFunc
 	parent      *RouterGroup
 	engine      *Engine
+	// 分组所在的作用域，Host、Header、Match创建的分组有自己的前缀树
+	scope *routeScope
+}
+
+// route 记录一条路由自身的处理链及其在前缀树上的节点
+type route struct {
+	method   string
+	pattern  string
+	handlers []HandlerFunc
+	node     *node
+	scope    *routeScope
+	// 通过RouteRef.Name设置的路由名
+	name string
 }
 
 type Engine struct {
 	*RouterGroup
-	router        *router
-	groups        []*RouterGroup
-	htmlTemplates *template.Template
-	funcMap       template.FuncMap
+	// 默认作用域，处理不属于任何带条件分组的路由
+	scope *routeScope
+	// 带条件的作用域，按优先顺序排列
+	scopes []*routeScope
+	// Context.HTML使用的模板引擎
+	htmlRender HTMLRender
+	funcMap    template.FuncMap
+	// SecureJSON输出JSON数组时添加的前缀
+	secureJSONPrefix string
+	// 已注册的路由，分组中间件变化时据此重新合并处理链
+	routes []*route
+	// 路由名到规范化后的pattern，供URL反向生成地址
+	namedRoutes map[string]string
+	// 路由未命中时执行的处理链
+	noRoute    []HandlerFunc
+	allNoRoute []HandlerFunc
+	// 路径存在但请求方法不匹配时执行的处理链
+	noMethod    []HandlerFunc
+	allNoMethod []HandlerFunc
+	// 复用Context，避免每个请求都分配
+	pool sync.Pool
+	// 为true时，方法不匹配返回405并带上Allow头，否则按404处理
+	HandleMethodNotAllowed bool
+	// 为true时，未注册OPTIONS路由的路径自动应答OPTIONS请求
+	HandleOPTIONS bool
+	// 为true时，直接连接的地址属于可信代理的请求，ClientIP优先使用X-Forwarded-For和X-Real-IP
+	ForwardedByClientIP bool
+	// 可信代理，默认为空，即不信任任何客户端提供的转发请求头
+	trustedProxies []*net.IPNet
+	// 为true时LoadHTML*加载的模板文件变化后自动重新解析，用于开发环境
+	HTMLDebug bool
+	// 解析multipart表单时保存在内存中的最大字节数，超出部分写入临时文件
+	MaxMultipartMemory int64
+
+	// 以下配置在Run系列方法创建http.Server时使用，零值表示不限制
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// 正在运行的服务器及生命周期钩子
+	mu      sync.Mutex
+	servers []*http.Server
+	// 已调用Shutdown，不再启动新的服务器
+	shuttingDown bool
+	onStart      []func(addr string)
+	onShutdown   []func()
+}
+
+// Any 注册时使用的全部请求方法
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect,
+	http.MethodTrace,
+}
+
+// SetTrustedProxies 设置可信代理的IP或CIDR，只有来自这些地址的请求才会使用X-Forwarded-For和X-Real-IP
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("gee: invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("gee: invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, cidr)
+	}
+	e.trustedProxies = nets
+	return nil
 }
 
-func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
-	e.funcMap = funcMap
+// isTrustedProxy ip无法解析时视为不可信
+func (e *Engine) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range e.trustedProxies {
+		if cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
 }
-func (e *Engine) LoadHTMLGlob(pattern string) {
-	e.htmlTemplates = template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
+
+// SecureJSONPrefix 设置SecureJSON使用的前缀
+func (e *Engine) SecureJSONPrefix(prefix string) {
+	e.secureJSONPrefix = prefix
 }
 
-// 处理请求
+// RouteInfo 描述一条已注册的路由
+type RouteInfo struct {
+	Method string
+	Path   string
+	Name   string
+	// 路由所在分组的匹配条件，如"Host=:tenant.example.com"，默认作用域为空
+	Condition   string
+	Handler     string
+	HandlerFunc HandlerFunc
+}
+
+// Routes 按请求方法和匹配优先级列出所有已注册的路由，带条件分组的路由排在默认作用域之前
+func (e *Engine) Routes() []RouteInfo {
+	names := make(map[*node]string)
+	for _, rt := range e.routes {
+		if rt.name != "" {
+			names[rt.node] = rt.name
+		}
+	}
+	routes := make([]RouteInfo, 0, len(e.routes))
+	for _, s := range append(e.scopes[:len(e.scopes):len(e.scopes)], e.scope) {
+		methods := make([]string, 0, len(s.router.roots))
+		for method := range s.router.roots {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		condition := s.condition()
+		for _, method := range methods {
+			var nodes []*node
+			s.router.roots[method].travel(&nodes)
+			for _, n := range nodes {
+				info := RouteInfo{Method: method, Path: n.pattern, Name: names[n], Condition: condition}
+				if len(n.handlers) > 0 {
+					info.HandlerFunc = n.handlers[len(n.handlers)-1]
+					info.Handler = nameOfFunction(info.HandlerFunc)
+				}
+				routes = append(routes, info)
+			}
+		}
+	}
+	return routes
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// 处理请求，中间件已在注册路由时合并进处理链，这里只需查路由
 func (e *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
-	// 匹配路径和已有的分组，如果前缀匹配，注册对应的中间件
-	for _, group := range e.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
+	// 从池中取出context结构，请求结束后归还，处理函数不能在返回后继续持有它
+	c := e.pool.Get().(*Context)
+	c.reset(w, req)
+	e.handle(c)
+	// 处理链只设置了状态码而没有写响应体时，在这里发出响应头
+	c.Writer.WriteHeaderNow()
+	e.pool.Put(c)
+}
+
+// handle 依次尝试满足条件的作用域，最后是默认作用域；都未命中时按404、405处理
+func (e *Engine) handle(c *Context) {
+	unmatched := e.scope
+	for _, s := range e.scopes {
+		c.Params = c.Params[:0]
+		if !s.match(c) {
+			continue
 		}
+		if s.router.handle(c) {
+			return
+		}
+		// 路径存在但方法不匹配时，由第一个这样的作用域返回405
+		if unmatched == e.scope {
+			if _, other := s.router.allowed(c.Method, c.Path); other != nil {
+				unmatched = s
+			}
+		}
+	}
+	c.Params = c.Params[:0]
+	if e.scope.router.handle(c) {
+		return
 	}
-	// 一个请求生成一个context结构
-	c := newContext(w, req)
-	c.engine = e
-	c.handlers = middlewares
-	e.router.handler(c)
+	if unmatched != e.scope {
+		// 恢复该作用域Host中的参数，供OPTIONS和405处理链使用
+		unmatched.match(c)
+	}
+	unmatched.router.handleUnmatched(c)
+}
+
+func (e *Engine) allocateContext() *Context {
+	maxParams := e.scope.router.maxParams
+	for _, s := range e.scopes {
+		// Host中的参数同样写入Params
+		if n := s.router.maxParams + len(s.matchers); n > maxParams {
+			maxParams = n
+		}
+	}
+	return &Context{engine: e, Params: make(Params, 0, maxParams)}
+}
+
+// combineHandlers 合并作用于pattern的全部分组中间件，并接上路由自身的处理链
+// 分组按前缀层级由浅到深排列，同一层级按创建顺序排列
+func (e *Engine) combineHandlers(s *routeScope, pattern string, handlers []HandlerFunc) []HandlerFunc {
+	merged := append([]HandlerFunc{}, s.middlewaresFor(pattern)...)
+	return append(merged, handlers...)
+}
+
+// rebuildHandlers 分组中间件或404/405处理链变化后，重新合并所有处理链
+func (e *Engine) rebuildHandlers() {
+	for _, rt := range e.routes {
+		e.bindRoute(rt)
+	}
+	// 未命中路由的请求不属于任何分组，只经过全局中间件
+	e.allNoRoute = append(append([]HandlerFunc{}, e.RouterGroup.middlewares...), e.noRoute...)
+	e.allNoMethod = append(append([]HandlerFunc{}, e.RouterGroup.middlewares...), e.noMethod...)
+}
+
+// bindRoute 把合并后的处理链挂到路由节点上
+func (e *Engine) bindRoute(rt *route) {
+	rt.node.handlers = e.combineHandlers(rt.scope, rt.pattern, rt.handlers)
+	rt.node.optionsHandlers = e.combineHandlers(rt.scope, rt.pattern, []HandlerFunc{defaultOptions})
+}
+
+// matchGroupPrefix 按路径段判断pattern是否属于前缀为prefix的分组，"/v1"不匹配"/v10"
+func matchGroupPrefix(pattern, prefix string) bool {
+	if !strings.HasPrefix(pattern, prefix) {
+		return false
+	}
+	return len(pattern) == len(prefix) || prefix == "" ||
+		prefix[len(prefix)-1] == '/' || pattern[len(prefix)] == '/'
 }
 
 func New() *Engine {
-	e := &Engine{router: newRouter()}
-	e.RouterGroup = &RouterGroup{engine: e}
-	e.groups = []*RouterGroup{
+	e := &Engine{
+		scope:                  newRouteScope(nil, nil),
+		secureJSONPrefix:       "while(1);",
+		noRoute:                []HandlerFunc{defaultNoRoute},
+		noMethod:               []HandlerFunc{defaultNoMethod},
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+		ForwardedByClientIP:    true,
+		MaxMultipartMemory:     defaultMultipartMemory,
+	}
+	e.RouterGroup = &RouterGroup{engine: e, scope: e.scope}
+	e.scope.groups = []*RouterGroup{
 		e.RouterGroup,
 	}
+	e.pool.New = func() interface{} {
+		return e.allocateContext()
+	}
+	e.rebuildHandlers()
 	return e
 }
 
+// NoRoute 设置404时的处理链，与普通路由一样经过全局中间件
+func (e *Engine) NoRoute(handlers ...HandlerFunc) {
+	e.noRoute = handlers
+	e.rebuildHandlers()
+}
+
+// NoMethod 设置405时的处理链，仅在HandleMethodNotAllowed为true时生效
+func (e *Engine) NoMethod(handlers ...HandlerFunc) {
+	e.noMethod = handlers
+	e.rebuildHandlers()
+}
+
+func defaultNoRoute(c *Context) {
+	c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
+}
+
+func defaultNoMethod(c *Context) {
+	c.String(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s\n", c.Path)
+}
+
+func defaultOptions(c *Context) {
+	c.Status(http.StatusNoContent)
+}
+
 // Group 生成子分组
 func (g *RouterGroup) Group(prefix string) *RouterGroup {
 	// 结构构建，加上结构本身的前缀
@@ -63,47 +321,68 @@ func (g *RouterGroup) Group(prefix string) *RouterGroup {
 		engine: g.engine,
 		prefix: g.prefix + prefix,
 		parent: g,
+		scope:  g.scope,
 	}
-	// engine需要记录所有的分组，方便匹配和加载中间件
-	g.engine.groups = append(g.engine.groups, group)
+	// 作用域需要记录其中所有的分组，方便合并中间件，按前缀层级保持有序
+	groups := append(g.scope.groups, group)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(parsePattern(groups[i].prefix)) < len(parsePattern(groups[j].prefix))
+	})
+	g.scope.groups = groups
 	return group
 }
 
-// Use 加载中间件
+// Use 加载中间件，对该分组前缀下已注册和之后注册的路由都生效
 func (g *RouterGroup) Use(middlewares ...HandlerFunc) {
 	g.middlewares = append(g.middlewares, middlewares...)
+	g.engine.rebuildHandlers()
 }
 
 // 新增路由
-func (g *RouterGroup) addRouter(method string, pattern string, handler HandlerFunc) {
+func (g *RouterGroup) addRouter(method string, pattern string, handlers []HandlerFunc) *route {
+	if len(handlers) == 0 {
+		panic("gee: route " + method + " " + g.prefix + pattern + " must have at least one handler")
+	}
 	pattern = g.prefix + pattern
-	g.engine.router.addRoute(method, pattern, handler)
+	e := g.engine
+	rt := &route{method: method, pattern: pattern, handlers: handlers, scope: g.scope}
+	rt.node = g.scope.router.addRoute(method, pattern, nil)
+	e.bindRoute(rt)
+	e.routes = append(e.routes, rt)
+	return rt
 }
-func (g *RouterGroup) GET(pattern string, handlerFunc HandlerFunc) {
-	g.addRouter("GET", pattern, handlerFunc)
+
+// Handle 以任意请求方法注册路由，handlers按顺序组成该路由独有的处理链
+func (g *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(method, pattern, handlers))
 }
-func (g *RouterGroup) POST(pattern string, handlerFunc HandlerFunc) {
-	g.addRouter("POST", pattern, handlerFunc)
+func (g *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodGet, pattern, handlers))
 }
-
-func (g *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	absolutePath := path.Join(g.prefix, relativePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(c *Context) {
-		file := c.Param("filePath")
-		if _, err := fs.Open(file); err != nil {
-			c.Status(http.StatusNotFound)
-			return
-		}
-		fileServer.ServeHTTP(c.Writer, c.Req)
-	}
+func (g *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodPost, pattern, handlers))
+}
+func (g *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodPut, pattern, handlers))
 }
-func (g *RouterGroup) Static(relativePath string, root string) {
-	handler := g.createStaticHandler(relativePath, http.Dir(root))
-	pattern := path.Join(relativePath, "/*filePath")
-	g.GET(pattern, handler)
+func (g *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodPatch, pattern, handlers))
+}
+func (g *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodDelete, pattern, handlers))
+}
+func (g *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodHead, pattern, handlers))
+}
+func (g *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) *RouteRef {
+	return g.newRouteRef(g.addRouter(http.MethodOptions, pattern, handlers))
 }
 
-func (e *Engine) RUN(addr string) {
-	http.ListenAndServe(addr, e)
+// Any 为同一路径注册全部常用请求方法
+func (g *RouterGroup) Any(pattern string, handlers ...HandlerFunc) *RouteRef {
+	ref := &RouteRef{engine: g.engine}
+	for _, method := range anyMethods {
+		ref.routes = append(ref.routes, g.addRouter(method, pattern, handlers))
+	}
+	return ref
 }
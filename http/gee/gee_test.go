@@ -0,0 +1,166 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// requestOption 在发出测试请求前修改请求
+type requestOption func(req *http.Request)
+
+func withHeader(key, value string) requestOption {
+	return func(req *http.Request) {
+		req.Header.Add(key, value)
+	}
+}
+
+func withHost(host string) requestOption {
+	return func(req *http.Request) {
+		req.Host = host
+	}
+}
+
+func performRequest(e *Engine, method, path string, opts ...requestOption) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for _, opt := range opts {
+		opt(req)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouterGroupMethods(t *testing.T) {
+	r := New()
+	r.PUT("/put", func(c *Context) { c.String(http.StatusOK, "put") })
+	r.DELETE("/delete", func(c *Context) { c.String(http.StatusOK, "delete") })
+	r.Handle("PROPFIND", "/dav", func(c *Context) { c.String(http.StatusOK, "propfind") })
+	r.Any("/any", func(c *Context) { c.String(http.StatusOK, c.Method) })
+
+	if w := performRequest(r, http.MethodPut, "/put"); w.Body.String() != "put" {
+		t.Fatalf("PUT /put got %q", w.Body.String())
+	}
+	if w := performRequest(r, http.MethodDelete, "/delete"); w.Body.String() != "delete" {
+		t.Fatalf("DELETE /delete got %q", w.Body.String())
+	}
+	if w := performRequest(r, "PROPFIND", "/dav"); w.Body.String() != "propfind" {
+		t.Fatalf("PROPFIND /dav got %q", w.Body.String())
+	}
+	for _, method := range anyMethods {
+		if w := performRequest(r, method, "/any"); w.Code != http.StatusOK || w.Body.String() != method {
+			t.Fatalf("%s /any got %d %q", method, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestNoRouteAndNoMethod(t *testing.T) {
+	r := New()
+	passed := 0
+	r.Use(func(c *Context) {
+		passed++
+		c.Next()
+	})
+	r.GET("/users", func(c *Context) {})
+	r.POST("/users", func(c *Context) {})
+
+	w := performRequest(r, http.MethodDelete, "/users")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Fatalf("expect 405 with Allow header, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	r.NoRoute(func(c *Context) { c.String(http.StatusNotFound, "custom 404") })
+	w = performRequest(r, http.MethodGet, "/missing")
+	if w.Code != http.StatusNotFound || w.Body.String() != "custom 404" {
+		t.Fatalf("expect custom 404, got %d %q", w.Code, w.Body.String())
+	}
+	if passed != 2 {
+		t.Fatalf("middleware should run for 404/405, ran %d times", passed)
+	}
+
+	r.HandleMethodNotAllowed = false
+	if w = performRequest(r, http.MethodDelete, "/users"); w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404 when HandleMethodNotAllowed is off, got %d", w.Code)
+	}
+}
+
+func TestRouteHandlersChain(t *testing.T) {
+	r := New()
+	var order []string
+	auth := func(c *Context) {
+		order = append(order, "auth")
+		if c.Query("token") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+		order = append(order, "auth-after")
+	}
+	limit := func(c *Context) {
+		order = append(order, "limit")
+	}
+	r.GET("/secret", auth, limit, func(c *Context) {
+		order = append(order, "handler")
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := performRequest(r, http.MethodGet, "/secret?token=1")
+	if w.Body.String() != "ok" || len(order) != 4 || order[3] != "auth-after" {
+		t.Fatalf("unexpected chain order %v", order)
+	}
+
+	order = nil
+	w = performRequest(r, http.MethodGet, "/secret")
+	if w.Code != http.StatusUnauthorized || len(order) != 1 {
+		t.Fatalf("abort should stop the chain, got %d %v", w.Code, order)
+	}
+}
+
+func TestGroupMiddlewaresResolvedPerRoute(t *testing.T) {
+	r := New()
+	var order []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			order = append(order, name)
+			c.Next()
+		}
+	}
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	v1.GET("/hello", func(c *Context) {})
+	r.GET("/v10/hello", func(c *Context) {})
+
+	performRequest(r, http.MethodGet, "/v10/hello")
+	performRequest(r, http.MethodGet, "/v1/missing")
+	if len(order) != 0 {
+		t.Fatalf("/v1 middleware should not run for /v10 or 404, got %v", order)
+	}
+
+	// 路由注册之后才加入的分组和中间件同样生效，外层分组先于内层执行
+	admin := v1.Group("/hello")
+	admin.Use(mark("hello"))
+	r.Use(mark("global"))
+	performRequest(r, http.MethodGet, "/v1/hello")
+	if len(order) != 3 || order[0] != "global" || order[1] != "v1" || order[2] != "hello" {
+		t.Fatalf("unexpected middleware order %v", order)
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	r := New()
+	copies := make(chan *Context, 1)
+	r.GET("/users/:id", func(c *Context) {
+		copies <- c.Copy()
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	performRequest(r, http.MethodGet, "/users/1")
+	cp := <-copies
+	// 第二个请求会复用池中的Context，之前的快照不应被改写
+	if w := performRequest(r, http.MethodGet, "/users/2"); w.Body.String() != "2" {
+		t.Fatalf("reused context got %q", w.Body.String())
+	}
+	<-copies
+	if cp.Param("id") != "1" || cp.Path != "/users/1" || !cp.IsAborted() {
+		t.Fatalf("unexpected copy %+v", cp)
+	}
+}
@@ -0,0 +1,341 @@
+package gee
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template/parse"
+)
+
+// HTMLRender 模板引擎，Context.HTML通过它取得一次渲染使用的Render
+// 模板缺失等错误应在Render时返回，Context.Render会在写出任何字节之前返回500
+type HTMLRender interface {
+	Instance(name string, data interface{}) Render
+}
+
+// htmlReloader 由引擎提供FuncMap的模板引擎，SetFuncMap后会重新解析，因此不受调用顺序影响
+// strict为false时只检查语法，引用了尚未注册的函数的模板推迟到SetFuncMap或第一次渲染时解析
+type htmlReloader interface {
+	setEngineFuncs(funcs template.FuncMap, strict bool) error
+}
+
+// SetHTMLRender 使用自定义的模板引擎，如HTMLTemplates
+func (e *Engine) SetHTMLRender(r HTMLRender) {
+	if reloader, ok := r.(htmlReloader); ok {
+		if err := reloader.setEngineFuncs(e.templateFuncs(), false); err != nil {
+			panic(err.Error())
+		}
+	}
+	e.htmlRender = r
+}
+
+// SetFuncMap 设置模板函数，在加载模板之前或之后调用都可以
+func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
+	e.funcMap = funcMap
+	if reloader, ok := e.htmlRender.(htmlReloader); ok {
+		if err := reloader.setEngineFuncs(e.templateFuncs(), true); err != nil {
+			panic(err.Error())
+		}
+	}
+}
+
+// htmlInstance 没有加载模板时同样以500结束请求
+func (e *Engine) htmlInstance(name string, data interface{}) Render {
+	if e.htmlRender == nil {
+		return htmlRender{name: name, data: data}
+	}
+	return e.htmlRender.Instance(name, data)
+}
+
+// templateFuncs 引擎提供给所有模板的函数，内置的url可以被SetFuncMap中的同名函数覆盖
+func (e *Engine) templateFuncs() template.FuncMap {
+	funcs := make(template.FuncMap, len(e.funcMap)+1)
+	funcs["url"] = e.URL
+	for k, v := range e.funcMap {
+		funcs[k] = v
+	}
+	return funcs
+}
+
+// LoadHTMLGlob 加载匹配pattern的全部模板，模板名为文件名
+func (e *Engine) LoadHTMLGlob(pattern string) {
+	e.loadHTML(templateSource{patterns: []string{pattern}})
+}
+
+// LoadHTMLFiles 加载指定的模板文件，模板名为文件名
+func (e *Engine) LoadHTMLFiles(files ...string) {
+	e.loadHTML(templateSource{patterns: files})
+}
+
+// LoadHTMLFS 从fs.FS加载模板，支持go:embed生成的embed.FS
+func (e *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	e.loadHTML(templateSource{fsys: fsys, patterns: patterns})
+}
+
+// SetHTMLTemplate 直接使用已解析好的模板，不支持热加载
+func (e *Engine) SetHTMLTemplate(t *template.Template) {
+	e.htmlRender = &globalHTMLRender{engine: e, set: &templateSet{tmpl: t}}
+}
+
+func (e *Engine) loadHTML(src templateSource) {
+	e.SetHTMLRender(&globalHTMLRender{engine: e, set: &templateSet{src: src}})
+}
+
+// globalHTMLRender LoadHTML*加载的单个模板集，按模板名渲染
+type globalHTMLRender struct {
+	engine *Engine
+	set    *templateSet
+}
+
+func (r *globalHTMLRender) setEngineFuncs(funcs template.FuncMap, strict bool) error {
+	return r.set.setFuncs(funcs, strict)
+}
+
+func (r *globalHTMLRender) Instance(name string, data interface{}) Render {
+	tmpl, _, err := r.set.get(r.engine.HTMLDebug)
+	return htmlRender{templates: tmpl, name: name, data: data, err: err}
+}
+
+// HTMLTemplates 多个相互独立的模板集，每个模板集由布局、局部模板和页面组合而成
+// 渲染时执行模板集中第一个文件对应的模板，通常是布局，页面通过{{define}}填充布局中的区块
+type HTMLTemplates struct {
+	// 开发模式，模板文件变化后在下一次渲染时重新解析
+	Debug bool
+	// 为nil时从本地文件读取
+	FS fs.FS
+	// 与引擎的FuncMap合并，同名时以这里为准
+	FuncMap template.FuncMap
+
+	mu          sync.RWMutex
+	sets        map[string]*templateSet
+	engineFuncs template.FuncMap
+	// 已经交给引擎，引擎的FuncMap已知
+	bound bool
+}
+
+func NewHTMLTemplates() *HTMLTemplates {
+	return &HTMLTemplates{sets: make(map[string]*templateSet)}
+}
+
+// Add 注册名为name的模板集，patterns可以是文件名或通配模式，第一个文件为执行入口
+// 已经交给引擎时立即解析并返回错误，否则在SetHTMLRender时解析
+func (t *HTMLTemplates) Add(name string, patterns ...string) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("gee: html template %q has no files", name)
+	}
+	set := &templateSet{src: templateSource{fsys: t.FS, patterns: patterns}}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sets == nil {
+		t.sets = make(map[string]*templateSet)
+	}
+	if t.bound {
+		if err := set.setFuncs(t.funcs(), true); err != nil {
+			return err
+		}
+	} else if _, err := set.src.files(); err != nil {
+		return err
+	}
+	t.sets[name] = set
+	return nil
+}
+
+func (t *HTMLTemplates) funcs() template.FuncMap {
+	funcs := make(template.FuncMap, len(t.engineFuncs)+len(t.FuncMap))
+	for k, v := range t.engineFuncs {
+		funcs[k] = v
+	}
+	for k, v := range t.FuncMap {
+		funcs[k] = v
+	}
+	return funcs
+}
+
+func (t *HTMLTemplates) setEngineFuncs(funcs template.FuncMap, strict bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.engineFuncs = funcs
+	t.bound = true
+	names := make([]string, 0, len(t.sets))
+	for name := range t.sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := t.sets[name].setFuncs(t.funcs(), strict); err != nil {
+			return fmt.Errorf("gee: html template %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (t *HTMLTemplates) Instance(name string, data interface{}) Render {
+	t.mu.RLock()
+	set, ok := t.sets[name]
+	t.mu.RUnlock()
+	if !ok {
+		return htmlRender{name: name, err: fmt.Errorf("gee: html template %q is not defined", name)}
+	}
+	tmpl, root, err := set.get(t.Debug)
+	return htmlRender{templates: tmpl, name: root, data: data, err: err}
+}
+
+// templateSource 模板文件的来源，fsys为nil时读取本地文件
+type templateSource struct {
+	fsys     fs.FS
+	patterns []string
+}
+
+// files 展开通配模式，每个模式都必须至少匹配一个文件
+func (s templateSource) files() ([]string, error) {
+	var files []string
+	for _, pattern := range s.patterns {
+		var (
+			matches []string
+			err     error
+		)
+		if s.fsys != nil {
+			matches, err = fs.Glob(s.fsys, pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("gee: pattern %q matches no template files", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// signature 文件列表及其修改时间，用于判断模板是否需要重新解析
+func (s templateSource) signature(files []string) string {
+	var b strings.Builder
+	for _, f := range files {
+		var info fs.FileInfo
+		var err error
+		if s.fsys != nil {
+			info, err = fs.Stat(s.fsys, f)
+		} else {
+			info, err = os.Stat(f)
+		}
+		b.WriteString(f)
+		if err == nil {
+			fmt.Fprintf(&b, ":%d:%d;", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return b.String()
+}
+
+func (s templateSource) readFile(name string) ([]byte, error) {
+	if s.fsys != nil {
+		return fs.ReadFile(s.fsys, name)
+	}
+	return os.ReadFile(name)
+}
+
+// templateSet 从同一批文件解析出的模板，开发模式下文件变化后重新解析
+type templateSet struct {
+	src   templateSource
+	mu    sync.Mutex
+	funcs template.FuncMap
+	tmpl  *template.Template
+	sig   string
+	// 第一个文件的模板名，作为执行入口
+	root string
+}
+
+func (s *templateSet) setFuncs(funcs template.FuncMap, strict bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.funcs = funcs
+	if s.src.patterns == nil {
+		// SetHTMLTemplate设置的模板没有来源，无法重新解析
+		return nil
+	}
+	if strict {
+		return s.parse()
+	}
+	if err := s.checkSyntax(); err != nil {
+		return err
+	}
+	// 语法正确但解析失败只可能是函数尚未注册，保持未解析状态
+	_ = s.parse()
+	return nil
+}
+
+// checkSyntax 不检查函数是否存在，只检查模板语法
+func (s *templateSet) checkSyntax() error {
+	files, err := s.src.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := s.src.readFile(f)
+		if err != nil {
+			return err
+		}
+		tree := parse.New(path.Base(filepath.ToSlash(f)))
+		tree.Mode = parse.SkipFuncCheck
+		if _, err = tree.Parse(string(data), "", "", map[string]*parse.Tree{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get 返回当前的模板和入口模板名，二者在锁内一起读取，debug时先检查文件是否变化
+func (s *templateSet) get(debug bool) (*template.Template, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tmpl == nil && s.src.patterns != nil {
+		if err := s.parse(); err != nil {
+			return nil, "", err
+		}
+	} else if debug && s.src.patterns != nil {
+		files, err := s.src.files()
+		if err != nil {
+			return nil, "", err
+		}
+		if s.src.signature(files) != s.sig {
+			if err = s.parse(); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if s.tmpl == nil {
+		return nil, "", fmt.Errorf("gee: html templates are not loaded")
+	}
+	return s.tmpl, s.root, nil
+}
+
+// parse 解析全部文件，出错时保留之前的模板，模板名与template.ParseFiles一样取文件名
+func (s *templateSet) parse() error {
+	files, err := s.src.files()
+	if err != nil {
+		return err
+	}
+	root := template.New("").Funcs(s.funcs)
+	for _, f := range files {
+		data, err := s.src.readFile(f)
+		if err != nil {
+			return err
+		}
+		name := path.Base(filepath.ToSlash(f))
+		if _, err = root.New(name).Parse(string(data)); err != nil {
+			return err
+		}
+	}
+	s.tmpl = root
+	s.sig = s.src.signature(files)
+	s.root = path.Base(filepath.ToSlash(files[0]))
+	return nil
+}
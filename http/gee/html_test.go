@@ -0,0 +1,195 @@
+package gee
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestSetFuncMapAfterLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "hello.tmpl", `hello {{upper .}}`)
+
+	r := New()
+	r.LoadHTMLGlob(filepath.Join(dir, "*"))
+	r.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "hello.tmpl", "gee")
+	})
+	if w := performRequest(r, http.MethodGet, "/"); w.Code != http.StatusOK || w.Body.String() != "hello GEE" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestLoadHTMLSyntaxError(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "bad.tmpl", `{{if .}}`)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("syntax error should panic at load time")
+		}
+	}()
+	New().LoadHTMLGlob(filepath.Join(dir, "*"))
+}
+
+func TestHTMLRenderError(t *testing.T) {
+	r := New()
+	r.GET("/unloaded", func(c *Context) {
+		c.HTML(http.StatusOK, "index.tmpl", nil)
+	})
+	if w := performRequest(r, http.MethodGet, "/unloaded"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("rendering without templates should fail with 500, got %d", w.Code)
+	}
+
+	r.LoadHTMLFS(fstest.MapFS{"page.tmpl": {Data: []byte(`<p>{{.Missing.Field}}</p>`)}}, "*.tmpl")
+	r.GET("/missing", func(c *Context) {
+		c.HTML(http.StatusOK, "missing.tmpl", nil)
+	})
+	r.GET("/exec", func(c *Context) {
+		c.HTML(http.StatusOK, "page.tmpl", H{"Missing": 1})
+	})
+	for _, path := range []string{"/missing", "/exec"} {
+		w := performRequest(r, http.MethodGet, path)
+		if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "<p>") ||
+			strings.HasPrefix(w.Header().Get("Content-Type"), MIMEHTML) {
+			t.Fatalf("%s: render error should be a clean 500, got %d %q %v", path, w.Code, w.Body.String(), w.Header())
+		}
+	}
+}
+
+func TestHTMLTemplatesLayouts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.tmpl":  {Data: []byte(`<html>{{template "nav" .}}{{block "content" .}}{{end}}</html>`)},
+		"layouts/admin.tmpl": {Data: []byte(`<admin>{{template "nav" .}}{{block "content" .}}{{end}}</admin>`)},
+		"partials/nav.tmpl":  {Data: []byte(`{{define "nav"}}<nav>{{site}}</nav>{{end}}`)},
+		"pages/home.tmpl":    {Data: []byte(`{{define "content"}}home {{.}}{{end}}`)},
+		"pages/users.tmpl":   {Data: []byte(`{{define "content"}}users {{shout .}}{{end}}`)},
+	}
+	tmpls := NewHTMLTemplates()
+	tmpls.FS = fsys
+	tmpls.FuncMap = template.FuncMap{"shout": strings.ToUpper}
+	for name, files := range map[string][]string{
+		"home":  {"layouts/base.tmpl", "partials/*.tmpl", "pages/home.tmpl"},
+		"users": {"layouts/admin.tmpl", "partials/*.tmpl", "pages/users.tmpl"},
+	} {
+		if err := tmpls.Add(name, files...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tmpls.Add("broken", "pages/none.tmpl"); err == nil {
+		t.Fatal("pattern without matches should be rejected")
+	}
+
+	r := New()
+	r.SetHTMLRender(tmpls)
+	r.SetFuncMap(template.FuncMap{"site": func() string { return "gee" }})
+	r.GET("/:page", func(c *Context) {
+		c.HTML(http.StatusOK, c.Param("page"), "jack")
+	})
+	for path, want := range map[string]string{
+		"/home":  "<html><nav>gee</nav>home jack</html>",
+		"/users": "<admin><nav>gee</nav>users JACK</admin>",
+	} {
+		if w := performRequest(r, http.MethodGet, path); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Fatalf("%s: want %q, got %d %q", path, want, w.Code, w.Body.String())
+		}
+	}
+	if w := performRequest(r, http.MethodGet, "/unknown"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("undefined template set should fail with 500, got %d", w.Code)
+	}
+}
+
+func TestHTMLDebugReload(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTemplate(t, dir, "page.tmpl", `v1`)
+
+	r := New()
+	r.HTMLDebug = true
+	r.LoadHTMLFiles(file)
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "page.tmpl", nil)
+	})
+	if w := performRequest(r, http.MethodGet, "/"); w.Body.String() != "v1" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+
+	writeTemplate(t, dir, "page.tmpl", `v2`)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(file, later, later)
+	if w := performRequest(r, http.MethodGet, "/"); w.Body.String() != "v2" {
+		t.Fatalf("changed template should be reloaded, got %q", w.Body.String())
+	}
+
+	// 解析失败时返回500，修复后恢复
+	writeTemplate(t, dir, "page.tmpl", `{{if}}`)
+	os.Chtimes(file, later.Add(time.Second), later.Add(time.Second))
+	if w := performRequest(r, http.MethodGet, "/"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("broken template should fail with 500, got %d %q", w.Code, w.Body.String())
+	}
+	writeTemplate(t, dir, "page.tmpl", `v3`)
+	os.Chtimes(file, later.Add(2*time.Second), later.Add(2*time.Second))
+	if w := performRequest(r, http.MethodGet, "/"); w.Body.String() != "v3" {
+		t.Fatalf("fixed template should be reloaded, got %q", w.Body.String())
+	}
+}
+
+func TestHTMLTemplatesConcurrentReload(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTemplate(t, dir, "page.tmpl", `page`)
+	tmpls := NewHTMLTemplates()
+	tmpls.Debug = true
+	if err := tmpls.Add("page", file); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.SetHTMLRender(tmpls)
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "page", nil)
+	})
+
+	// 请求期间文件不断变化，每次请求都可能重新解析
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			later := time.Now().Add(time.Duration(i) * time.Second)
+			os.Chtimes(file, later, later)
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if w := performRequest(r, http.MethodGet, "/"); w.Body.String() != "page" {
+					t.Errorf("unexpected response %d %q", w.Code, w.Body.String())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-done
+}
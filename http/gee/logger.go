@@ -1,14 +1,215 @@
 package gee
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// LogParams 一条访问日志包含的信息，状态码和字节数取自ResponseWriter，处理函数直接写c.Writer也能记录准确
+type LogParams struct {
+	TimeStamp  time.Time     `json:"time"`
+	Latency    time.Duration `json:"latency"`
+	ClientIP   string        `json:"client_ip"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Query      string        `json:"query,omitempty"`
+	Proto      string        `json:"proto"`
+	StatusCode int           `json:"status"`
+	BodySize   int           `json:"body_size"`
+	RequestID  string        `json:"request_id,omitempty"`
+	// 认证中间件验证过的用户名，未认证时为空
+	User      string `json:"user,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	Referer   string `json:"referer,omitempty"`
+	// 耗时超过LoggerConfig.SlowThreshold
+	Slow bool `json:"slow,omitempty"`
+}
+
+// LogFormatter 把一条访问日志格式化为一行文本
+type LogFormatter func(params LogParams) string
+
+// LoggerConfig 访问日志配置，零值即为默认配置
+type LoggerConfig struct {
+	// 日志格式，默认为TextLogFormatter
+	Formatter LogFormatter
+	// 日志输出，默认为标准库log的输出
+	Output io.Writer
+	// 设置后改为输出到slog，Formatter和Output不再生效
+	Slog *slog.Logger
+	// 不记录日志的路径
+	SkipPaths []string
+	// 只记录耗时不低于该值的请求，0表示全部记录
+	MinLatency time.Duration
+	// 耗时超过该值的请求会被标记为慢请求，输出到slog时使用Warn级别，0表示不标记
+	SlowThreshold time.Duration
+	// 读取请求ID的请求头，响应头中存在同名字段时优先使用，默认为X-Request-ID
+	RequestIDHeader string
+}
+
+// TextLogFormatter 默认的单行文本格式
+func TextLogFormatter(p LogParams) string {
+	slow := ""
+	if p.Slow {
+		slow = " | SLOW"
+	}
+	requestID := ""
+	if p.RequestID != "" {
+		requestID = " | " + p.RequestID
+	}
+	path := p.Path
+	if p.Query != "" {
+		path += "?" + p.Query
+	}
+	return fmt.Sprintf("[GEE] %s | %3d | %13v | %15s | %-7s %q | %dB%s%s\n",
+		p.TimeStamp.Format("2006/01/02 - 15:04:05"), p.StatusCode, p.Latency, p.ClientIP,
+		p.Method, path, p.BodySize, requestID, slow)
+}
+
+// JSONLogFormatter 每行一个JSON对象，latency以纳秒表示
+func JSONLogFormatter(p LogParams) string {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Sprintf("{\"error\":%q}\n", err.Error())
+	}
+	return string(data) + "\n"
+}
+
+// CombinedLogFormatter Apache combined日志格式
+func CombinedLogFormatter(p LogParams) string {
+	user := p.User
+	if user == "" {
+		user = "-"
+	}
+	path := p.Path
+	if p.Query != "" {
+		path += "?" + p.Query
+	}
+	size := "-"
+	if p.BodySize > 0 {
+		size = strconv.Itoa(p.BodySize)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %q %q\n",
+		p.ClientIP, user, p.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+		p.Method, path, p.Proto, p.StatusCode, size, p.Referer, p.UserAgent)
+}
+
+// Logger 使用默认配置的访问日志中间件
 func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+// LoggerWithFormatter 使用指定格式的访问日志中间件
+func LoggerWithFormatter(f LogFormatter) HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{Formatter: f})
+}
+
+// LoggerWithWriter 输出到w的访问日志中间件，skipPaths中的路径不记录
+func LoggerWithWriter(w io.Writer, skipPaths ...string) HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{Output: w, SkipPaths: skipPaths})
+}
+
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc {
+	formatter := conf.Formatter
+	if formatter == nil {
+		formatter = TextLogFormatter
+	}
+	out := conf.Output
+	if out == nil {
+		out = log.Writer()
+	}
+	requestIDHeader := conf.RequestIDHeader
+	if requestIDHeader == "" {
+		requestIDHeader = "X-Request-ID"
+	}
+	skip := make(map[string]struct{}, len(conf.SkipPaths))
+	for _, p := range conf.SkipPaths {
+		skip[p] = struct{}{}
+	}
+	// 保证并发请求的日志行不会交错
+	var mu sync.Mutex
+
 	return func(c *Context) {
-		t := time.Now()
+		start := time.Now()
+		path := c.Req.URL.Path
+		query := c.Req.URL.RawQuery
 		c.Next()
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+
+		if _, ok := skip[path]; ok {
+			return
+		}
+		latency := time.Since(start)
+		if latency < conf.MinLatency {
+			return
+		}
+		params := LogParams{
+			TimeStamp:  start,
+			Latency:    latency,
+			ClientIP:   c.ClientIP(),
+			Method:     c.Req.Method,
+			Path:       path,
+			Query:      query,
+			Proto:      c.Req.Proto,
+			StatusCode: c.Writer.Status(),
+			BodySize:   c.Writer.Size(),
+			UserAgent:  c.Req.UserAgent(),
+			Referer:    c.Req.Referer(),
+			Slow:       conf.SlowThreshold > 0 && latency > conf.SlowThreshold,
+		}
+		if params.BodySize < 0 {
+			params.BodySize = 0
+		}
+		params.RequestID = c.Writer.Header().Get(requestIDHeader)
+		if params.RequestID == "" {
+			params.RequestID = c.Req.Header.Get(requestIDHeader)
+		}
+		// 只记录认证中间件验证过的身份，未验证的Authorization请求头可以被任意伪造
+		if p := c.Principal(); p != nil {
+			params.User = p.Name
+		}
+
+		if conf.Slog != nil {
+			logToSlog(c, conf.Slog, params)
+			return
+		}
+		line := formatter(params)
+		mu.Lock()
+		_, _ = io.WriteString(out, line)
+		mu.Unlock()
+	}
+}
+
+func logToSlog(c *Context, logger *slog.Logger, p LogParams) {
+	level := slog.LevelInfo
+	switch {
+	case p.StatusCode >= 500:
+		level = slog.LevelError
+	case p.Slow:
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.Int("status", p.StatusCode),
+		slog.String("method", p.Method),
+		slog.String("path", p.Path),
+		slog.String("query", p.Query),
+		slog.String("client_ip", p.ClientIP),
+		slog.Duration("latency", p.Latency),
+		slog.Int("body_size", p.BodySize),
+		slog.String("user_agent", p.UserAgent),
+	}
+	if p.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", p.RequestID))
+	}
+	if p.User != "" {
+		attrs = append(attrs, slog.String("user", p.User))
+	}
+	if p.Slow {
+		attrs = append(attrs, slog.Bool("slow", true))
 	}
-}
\ No newline at end of file
+	logger.LogAttrs(c.Req.Context(), level, "request", attrs...)
+}
@@ -0,0 +1,132 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerRecordsDirectWrites(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{
+		Output:    buf,
+		Formatter: JSONLogFormatter,
+		SkipPaths: []string{"/health"},
+	}))
+	r.GET("/raw", func(c *Context) {
+		c.Writer.WriteHeader(http.StatusTeapot)
+		_, _ = c.Writer.Write([]byte("short and stout"))
+	})
+	r.GET("/health", func(c *Context) {})
+	// httptest请求来自192.0.2.1，经过内网的两层代理
+	if err := r.SetTrustedProxies([]string{"192.0.2.1", "10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/raw?a=1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	performRequest(r, http.MethodGet, "/health")
+
+	var p LogParams
+	if err := json.Unmarshal(buf.Bytes(), &p); err != nil {
+		t.Fatalf("expect exactly one json line, got %q", buf.String())
+	}
+	if p.StatusCode != http.StatusTeapot || p.BodySize != 15 || p.RequestID != "req-1" ||
+		p.ClientIP != "10.0.0.1" || p.Query != "a=1" {
+		t.Fatalf("unexpected log params %+v", p)
+	}
+}
+
+func TestLoggerUser(t *testing.T) {
+	buf := &bytes.Buffer{}
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{Output: buf, Formatter: JSONLogFormatter}))
+	r.GET("/public", func(c *Context) {})
+	r.GET("/admin", BasicAuth(Accounts{"admin": "secret"}), func(c *Context) {})
+
+	for _, tt := range []struct {
+		path, user, password, want string
+	}{
+		{"/admin", "admin", "secret", "admin"},
+		// 没有经过认证的Authorization请求头不可信，不能记到日志里
+		{"/public", "root", "forged", ""},
+		{"/admin", "root", "forged", ""},
+	} {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.SetBasicAuth(tt.user, tt.password)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		var p LogParams
+		if err := json.Unmarshal(buf.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.User != tt.want {
+			t.Fatalf("%s as %s: want user %q, got %q", tt.path, tt.user, tt.want, p.User)
+		}
+	}
+}
+
+func TestLoggerFormatsAndSlog(t *testing.T) {
+	p := LogParams{ClientIP: "127.0.0.1", Method: "GET", Path: "/a", Proto: "HTTP/1.1", StatusCode: 200, BodySize: 5, User: "frank"}
+	if line := CombinedLogFormatter(p); !strings.HasPrefix(line, `127.0.0.1 - frank [`) ||
+		!strings.HasSuffix(line, `"GET /a HTTP/1.1" 200 5 "" ""`+"\n") {
+		t.Fatalf("unexpected combined log %q", line)
+	}
+
+	buf := &bytes.Buffer{}
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{Slog: slog.New(slog.NewTextHandler(buf, nil))}))
+	r.GET("/fail", func(c *Context) { c.Status(http.StatusInternalServerError) })
+	performRequest(r, http.MethodGet, "/fail")
+	if line := buf.String(); !strings.Contains(line, "level=ERROR") || !strings.Contains(line, "status=500") {
+		t.Fatalf("unexpected slog output %q", line)
+	}
+}
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	r := New()
+	var ip string
+	r.GET("/ip", func(c *Context) { ip = c.ClientIP() })
+	request := func(remote string, header ...string) string {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remote
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return ip
+	}
+
+	// 默认不信任任何代理，客户端伪造的请求头被忽略
+	if got := request("203.0.113.9:1234", "X-Forwarded-For", "1.2.3.4", "X-Real-IP", "5.6.7.8"); got != "203.0.113.9" {
+		t.Fatalf("forwarded headers should be ignored by default, got %q", got)
+	}
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8", "::1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		remote, forwarded, realIP, want string
+	}{
+		{"203.0.113.9:1234", "1.2.3.4", "", "203.0.113.9"},
+		{"10.0.0.2:1234", "1.2.3.4", "", "1.2.3.4"},
+		// 客户端在最左侧伪造的地址被代理追加的真实地址取代
+		{"10.0.0.2:1234", "6.6.6.6, 1.2.3.4, 10.0.0.3", "", "1.2.3.4"},
+		{"[::1]:1234", "", "5.6.7.8", "5.6.7.8"},
+		{"10.0.0.2:1234", "not-an-ip", "", "10.0.0.2"},
+	} {
+		header := []string{"X-Forwarded-For", tt.forwarded, "X-Real-IP", tt.realIP}
+		if got := request(tt.remote, header...); got != tt.want {
+			t.Fatalf("%s %q %q: want %q, got %q", tt.remote, tt.forwarded, tt.realIP, tt.want, got)
+		}
+	}
+	if err := r.SetTrustedProxies([]string{"10.0.0.300"}); err == nil {
+		t.Fatal("invalid proxy should be rejected")
+	}
+}
@@ -0,0 +1,295 @@
+package gee
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm 限流算法
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket 令牌桶，按Limit/Window的速率补充令牌，允许Burst大小的突发
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow 滑动窗口，按上一个窗口的计数加权估算最近Window内的请求数
+	SlidingWindow
+)
+
+// RateLimitState 限流算法保存在存储中的状态，不同算法使用不同的字段
+type RateLimitState struct {
+	// 令牌桶剩余的令牌数
+	Tokens float64
+	// 令牌桶上次补充令牌的时间，或滑动窗口当前窗口的起点
+	Time time.Time
+	// 滑动窗口当前窗口和上一个窗口的请求数
+	Count     int
+	PrevCount int
+}
+
+// RateLimitStore 保存每个key的限流状态，可以用Redis等实现以便多个实例共享
+// Update需要保证同一个key上的读-改-写是原子的，ttl内没有再访问的key可以被清除
+type RateLimitStore interface {
+	Update(key string, ttl time.Duration, fn func(state *RateLimitState)) error
+}
+
+// RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// 配额完全恢复的时间
+	Reset time.Time
+	// 被拒绝时距离下一次可以成功请求的时间
+	RetryAfter time.Duration
+}
+
+// RateLimitConfig 限流配置，Limit和Window必须设置
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+	// 每个Window内允许的请求数
+	Limit  int
+	Window time.Duration
+	// 令牌桶的容量，默认等于Limit
+	Burst int
+	// 区分限流对象的key，默认为KeyByIP，返回空字符串时不限流
+	KeyFunc func(c *Context) string
+	// 默认为每个中间件独立的内存存储
+	Store RateLimitStore
+	// 超出限制时的响应，默认返回429
+	LimitReached HandlerFunc
+}
+
+// KeyByIP 按客户端IP限流
+func KeyByIP(c *Context) string {
+	return c.ClientIP()
+}
+
+// KeyByRoute 按路由限流，同一路由的所有客户端共享配额
+func KeyByRoute(c *Context) string {
+	if c.FullPath() == "" {
+		return c.Method + " " + c.Path
+	}
+	return c.Method + " " + c.FullPath()
+}
+
+// KeyByRouteAndIP 每个客户端在每个路由上有独立的配额
+func KeyByRouteAndIP(c *Context) string {
+	return KeyByRoute(c) + "|" + c.ClientIP()
+}
+
+// KeyByAPIKey 按请求头中的API Key限流，请求头不存在时读取同名的查询参数
+func KeyByAPIKey(name string) func(c *Context) string {
+	return func(c *Context) string {
+		if key := c.Req.Header.Get(name); key != "" {
+			return key
+		}
+		return c.Query(name)
+	}
+}
+
+// RateLimit 限流中间件，响应中带上X-RateLimit-*头，超出限制时带上Retry-After并返回429
+func RateLimit(conf RateLimitConfig) HandlerFunc {
+	if conf.Limit <= 0 || conf.Window <= 0 {
+		panic("gee: rate limit requires a positive Limit and Window")
+	}
+	if conf.Burst <= 0 {
+		conf.Burst = conf.Limit
+	}
+	if conf.KeyFunc == nil {
+		conf.KeyFunc = KeyByIP
+	}
+	if conf.Store == nil {
+		conf.Store = NewMemoryStore()
+	}
+	limitReached := conf.LimitReached
+	if limitReached == nil {
+		limitReached = func(c *Context) {
+			c.Fail(http.StatusTooManyRequests, "Too Many Requests")
+		}
+	}
+	ttl := conf.ttl()
+
+	return func(c *Context) {
+		key := conf.KeyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		var result RateLimitResult
+		now := time.Now()
+		err := conf.Store.Update(key, ttl, func(state *RateLimitState) {
+			result = conf.take(state, now)
+		})
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(ceilUnix(result.Reset), 10))
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(result.RetryAfter.Seconds())), 10))
+			c.Abort()
+			limitReached(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
+
+// ttl 状态不再影响限流结果所需的时间，之后可以从存储中清除
+func (conf *RateLimitConfig) ttl() time.Duration {
+	if conf.Algorithm == SlidingWindow {
+		return 2 * conf.Window
+	}
+	return conf.Window * time.Duration(conf.Burst) / time.Duration(conf.Limit)
+}
+
+// take 按配置的算法消耗一次配额
+func (conf *RateLimitConfig) take(state *RateLimitState, now time.Time) RateLimitResult {
+	if conf.Algorithm == SlidingWindow {
+		return conf.takeSlidingWindow(state, now)
+	}
+	return conf.takeTokenBucket(state, now)
+}
+
+func (conf *RateLimitConfig) takeTokenBucket(state *RateLimitState, now time.Time) RateLimitResult {
+	// 每纳秒补充的令牌数
+	rate := float64(conf.Limit) / float64(conf.Window)
+	burst := float64(conf.Burst)
+	if state.Time.IsZero() {
+		state.Tokens = burst
+	} else if elapsed := now.Sub(state.Time); elapsed > 0 {
+		state.Tokens = math.Min(burst, state.Tokens+float64(elapsed)*rate)
+	}
+	state.Time = now
+
+	result := RateLimitResult{Limit: conf.Burst}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - state.Tokens) / rate))
+	}
+	result.Remaining = int(state.Tokens)
+	result.Reset = now.Add(time.Duration(math.Ceil((burst - state.Tokens) / rate)))
+	return result
+}
+
+func (conf *RateLimitConfig) takeSlidingWindow(state *RateLimitState, now time.Time) RateLimitResult {
+	start := now.Truncate(conf.Window)
+	if !state.Time.Equal(start) {
+		if state.Time.Equal(start.Add(-conf.Window)) {
+			state.PrevCount = state.Count
+		} else {
+			state.PrevCount = 0
+		}
+		state.Count = 0
+		state.Time = start
+	}
+	// 当前窗口已经过去的比例，上一个窗口的计数按剩余比例计入
+	elapsed := float64(now.Sub(start)) / float64(conf.Window)
+	estimated := float64(state.PrevCount)*(1-elapsed) + float64(state.Count)
+
+	result := RateLimitResult{Limit: conf.Limit, Reset: start.Add(conf.Window)}
+	if estimated+1 <= float64(conf.Limit) {
+		state.Count++
+		estimated++
+		result.Allowed = true
+	} else if state.Count < conf.Limit && state.PrevCount > 0 {
+		// 等上一个窗口的权重下降到足以容纳一次请求
+		need := 1 - float64(conf.Limit-1-state.Count)/float64(state.PrevCount)
+		result.RetryAfter = time.Duration((need - elapsed) * float64(conf.Window))
+	} else {
+		result.RetryAfter = result.Reset.Sub(now)
+	}
+	result.Remaining = conf.Limit - int(math.Ceil(estimated))
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	return result
+}
+
+const (
+	memoryStoreShards = 64
+	// 每个分片清理过期key的最小间隔
+	memoryStoreSweepInterval = time.Minute
+)
+
+// MemoryStore 进程内的限流存储，按key的哈希分片加锁，过期的key在访问时顺带清理
+type MemoryStore struct {
+	shards [memoryStoreShards]memoryStoreShard
+	// 当前时间，测试中可以替换
+	now func() time.Time
+}
+
+type memoryStoreShard struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryStoreEntry
+	lastSweep time.Time
+}
+
+type memoryStoreEntry struct {
+	state   RateLimitState
+	expires time.Time
+}
+
+var _ RateLimitStore = &MemoryStore{}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*memoryStoreEntry)
+	}
+	return s
+}
+
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state *RateLimitState)) error {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%memoryStoreShards]
+
+	now := s.now()
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.Sub(shard.lastSweep) >= memoryStoreSweepInterval {
+		for k, e := range shard.entries {
+			if now.After(e.expires) {
+				delete(shard.entries, k)
+			}
+		}
+		shard.lastSweep = now
+	}
+	e, ok := shard.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &memoryStoreEntry{}
+		shard.entries[key] = e
+	}
+	fn(&e.state)
+	e.expires = now.Add(ttl)
+	return nil
+}
+
+// Len 当前保存的key数量，包括已过期但尚未清理的
+func (s *MemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		n += len(s.shards[i].entries)
+		s.shards[i].mu.Unlock()
+	}
+	return n
+}
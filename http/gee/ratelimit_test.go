@@ -0,0 +1,130 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	conf := RateLimitConfig{Algorithm: TokenBucket, Limit: 10, Window: 10 * time.Second, Burst: 3}
+	var state RateLimitState
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if res := conf.take(&state, now); !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d should be allowed with %d remaining, got %+v", i, 2-i, res)
+		}
+	}
+	res := conf.take(&state, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("bucket should be empty, got %+v", res)
+	}
+	// 每秒补充一个令牌
+	if res = conf.take(&state, now.Add(time.Second)); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("refilled token should be allowed, got %+v", res)
+	}
+	if res = conf.take(&state, now.Add(time.Minute)); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("bucket should be capped at burst, got %+v", res)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	conf := RateLimitConfig{Algorithm: SlidingWindow, Limit: 4, Window: time.Minute}
+	var state RateLimitState
+	start := time.Unix(6000, 0) // 恰好是窗口起点
+	for i := 0; i < 4; i++ {
+		if res := conf.take(&state, start.Add(30*time.Second)); !res.Allowed {
+			t.Fatalf("request %d should be allowed, got %+v", i, res)
+		}
+	}
+	res := conf.take(&state, start.Add(30*time.Second))
+	if res.Allowed || res.Remaining != 0 || res.RetryAfter != 30*time.Second {
+		t.Fatalf("window should be full, got %+v", res)
+	}
+	// 下一个窗口过去一半时，上一个窗口的4次请求按一半计入
+	next := start.Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if res = conf.take(&state, next); !res.Allowed {
+			t.Fatalf("request %d in next window should be allowed, got %+v", i, res)
+		}
+	}
+	if res = conf.take(&state, next); res.Allowed || res.RetryAfter != 15*time.Second {
+		t.Fatalf("weighted window should be full, got %+v", res)
+	}
+	// 隔了不止一个窗口，之前的计数全部失效
+	if res = conf.take(&state, start.Add(5*time.Minute)); !res.Allowed || res.Remaining != 3 {
+		t.Fatalf("stale window should be reset, got %+v", res)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.Use(RateLimit(RateLimitConfig{Limit: 2, Window: time.Minute, KeyFunc: KeyByAPIKey("X-API-Key")}))
+	api.GET("/items/:id", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	request := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		w := request("/api/items/1", "alice")
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" ||
+			w.Header().Get("X-RateLimit-Remaining") == "" || w.Header().Get("X-RateLimit-Reset") == "" {
+			t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+		}
+	}
+	w := request("/api/items/2", "alice")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" ||
+		w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	// 不同的API Key配额独立，没有API Key的请求不限流
+	if w = request("/api/items/1", "bob"); w.Code != http.StatusOK {
+		t.Fatalf("other key should not be limited, got %d", w.Code)
+	}
+	if w = request("/api/items/1", ""); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("request without key should not be limited, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	r := New()
+	var keys []string
+	r.GET("/user/:id", func(c *Context) {
+		keys = append(keys, KeyByRoute(c), KeyByRouteAndIP(c))
+	})
+	r.SetTrustedProxies([]string{"192.0.2.1"})
+	req := httptest.NewRequest(http.MethodGet, "/user/42", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if len(keys) != 2 || keys[0] != "GET /user/:id" || keys[1] != "GET /user/:id|10.0.0.1" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	s := NewMemoryStore()
+	clock := time.Now()
+	s.now = func() time.Time { return clock }
+	incr := func(state *RateLimitState) { state.Count++ }
+	s.Update("a", time.Millisecond, incr)
+	clock = clock.Add(2 * time.Millisecond)
+	var count int
+	s.Update("a", time.Minute, func(state *RateLimitState) {
+		incr(state)
+		count = state.Count
+	})
+	if count != 1 {
+		t.Fatalf("expired state should be reset, got count %d", count)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("unexpected store size %d", s.Len())
+	}
+}
@@ -1,36 +1,165 @@
 package gee
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httputil"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// RecoveryFunc 处理panic并写出响应，只会在响应头尚未发出时调用
+type RecoveryFunc func(c *Context, err interface{})
+
+// PanicReport 一次panic的现场，交给RecoveryConfig.Reporter上报到错误追踪系统
+type PanicReport struct {
+	Time  time.Time
+	Err   interface{}
+	Stack string
+	// 不含请求体的请求报文，Authorization和Cookie已被隐去
+	Request string
+	// 客户端已断开连接(broken pipe、connection reset)引起的panic
+	BrokenPipe bool
+}
+
+type RecoveryConfig struct {
+	// 日志输出，默认为标准库log的输出
+	Output io.Writer
+	// 写出panic响应，默认返回500
+	Handler RecoveryFunc
+	// 每次panic都会调用，包括客户端断开连接的情况，http.ErrAbortHandler除外
+	Reporter func(report PanicReport)
+}
+
 func Recovery() HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{})
+}
+
+// RecoveryWithWriter 日志写到out，可选地指定处理panic的函数
+func RecoveryWithWriter(out io.Writer, handler ...RecoveryFunc) HandlerFunc {
+	conf := RecoveryConfig{Output: out}
+	if len(handler) > 0 {
+		conf.Handler = handler[0]
+	}
+	return RecoveryWithConfig(conf)
+}
+
+// CustomRecovery 由handle决定panic后的响应
+func CustomRecovery(handle RecoveryFunc) HandlerFunc {
+	return RecoveryWithConfig(RecoveryConfig{Handler: handle})
+}
+
+func RecoveryWithConfig(conf RecoveryConfig) HandlerFunc {
+	out := conf.Output
+	if out == nil {
+		out = log.Writer()
+	}
+	logger := log.New(out, "", log.LstdFlags)
+	handle := conf.Handler
+	if handle == nil {
+		handle = defaultRecovery
+	}
 	return func(c *Context) {
 		defer func() {
-			if err := recover(); err != nil{
-				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
-				c.Fail(http.StatusInternalServerError,"Internal Server Error")
+			err := recover()
+			if err == nil {
+				return
+			}
+			// http.ErrAbortHandler是主动中止响应，交还给net/http断开连接，不记录也不上报
+			if e, ok := err.(error); ok && errors.Is(e, http.ErrAbortHandler) {
+				panic(http.ErrAbortHandler)
+			}
+			report := PanicReport{
+				Time:       time.Now(),
+				Err:        err,
+				Request:    dumpRequest(c.Req),
+				BrokenPipe: isBrokenPipe(err),
 			}
+			if report.BrokenPipe {
+				// 客户端已经断开，只记录一行，也无法再写响应
+				logger.Printf("[Recovery] connection closed by client: %v %s %s", err, c.Method, c.Path)
+			} else {
+				report.Stack = trace(fmt.Sprintf("%v", err))
+				logger.Printf("[Recovery] panic recovered:\n%s\n%s\n\n", report.Request, report.Stack)
+			}
+			if conf.Reporter != nil {
+				conf.Reporter(report)
+			}
+			if report.BrokenPipe {
+				c.Abort()
+				return
+			}
+			// 响应头已经发出时无法再改写状态码，断开连接，避免客户端把不完整的响应当作成功
+			if c.Writer.Written() {
+				c.Abort()
+				panic(http.ErrAbortHandler)
+			}
+			handle(c, err)
+			c.Abort()
 		}()
 		c.Next()
 	}
+}
+
+func defaultRecovery(c *Context, _ interface{}) {
+	c.Fail(http.StatusInternalServerError, "Internal Server Error")
+}
+
+// isBrokenPipe 判断panic是否由客户端断开连接引起
+func isBrokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(e.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
 
+// dumpRequest 输出请求行和请求头，隐去认证信息
+func dumpRequest(req *http.Request) string {
+	raw, err := httputil.DumpRequest(req, false)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\r\n")
+	for i, line := range lines {
+		name := strings.ToLower(strings.SplitN(line, ":", 2)[0])
+		if name == "authorization" || name == "cookie" {
+			lines[i] = line[:len(name)] + ": *"
+		}
+	}
+	return strings.Join(lines, "\n")
 }
+
+// trace 输出完整的调用栈，跳过runtime和recovery自身的帧
 func trace(message string) string {
-	var pcs [32]uintptr
-	n := runtime.Callers(3, pcs[:]) // skip first 3 caller
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(3, pcs) // skip first 3 caller
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
 
 	var str strings.Builder
 	str.WriteString(message + "\nTraceback:")
-	for _, pc := range pcs[:n] {
-		fn := runtime.FuncForPC(pc)
-		file, line := fn.FileLine(pc)
-		str.WriteString(fmt.Sprintf("\n\t%s:%d", file, line))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		str.WriteString(fmt.Sprintf("\n\t%s:%d %s", frame.File, frame.Line, frame.Function))
+		if !more {
+			break
+		}
 	}
 	return str.String()
-}
\ No newline at end of file
+}
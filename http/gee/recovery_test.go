@@ -0,0 +1,93 @@
+package gee
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCustomRecoveryAndReporter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	var reports []PanicReport
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{
+		Output: buf,
+		Handler: func(c *Context, err interface{}) {
+			c.String(http.StatusServiceUnavailable, "recovered: %v", err)
+		},
+		Reporter: func(report PanicReport) { reports = append(reports, report) },
+	}))
+	r.GET("/panic", func(c *Context) { panic("boom") })
+	r.GET("/pipe", func(c *Context) {
+		panic(fmt.Errorf("write: %w", os.NewSyscallError("write", syscall.EPIPE)))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "recovered: boom" {
+		t.Fatalf("custom handler not used, got %d %q", w.Code, w.Body.String())
+	}
+	if len(reports) != 1 || !strings.Contains(reports[0].Stack, "TestCustomRecoveryAndReporter") ||
+		strings.Contains(reports[0].Request, "secret") {
+		t.Fatalf("unexpected report %+v", reports)
+	}
+
+	buf.Reset()
+	performRequest(r, http.MethodGet, "/pipe")
+	if len(reports) != 2 || !reports[1].BrokenPipe || strings.Contains(buf.String(), "Traceback") {
+		t.Fatalf("broken pipe should be logged quietly, got %q", buf.String())
+	}
+}
+
+func TestRecoveryAbortsConnection(t *testing.T) {
+	// Reporter在服务端的goroutine中调用
+	reports := make(chan PanicReport, 2)
+	r := New()
+	r.Use(RecoveryWithConfig(RecoveryConfig{
+		Output:   io.Discard,
+		Reporter: func(report PanicReport) { reports <- report },
+	}))
+	r.GET("/partial", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		c.Writer.Flush()
+		panic("boom")
+	})
+	r.GET("/abort", func(c *Context) {
+		panic(http.ErrAbortHandler)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// 响应头已经发出，客户端只能读到被截断的响应体，而不是完整的200
+	resp, err := http.Get(srv.URL + "/partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Fatalf("partial response should be cut off, got %d %q", resp.StatusCode, body)
+	}
+	if report := <-reports; report.BrokenPipe {
+		t.Fatalf("panic after a partial write should still be reported, got %+v", report)
+	}
+
+	// http.ErrAbortHandler交还给net/http，不写500，也不当作客户端断开
+	if resp, err := http.Get(srv.URL + "/abort"); err == nil {
+		resp.Body.Close()
+		t.Fatalf("aborted handler should not get a response, got %d", resp.StatusCode)
+	}
+	select {
+	case report := <-reports:
+		t.Fatalf("http.ErrAbortHandler should not be reported, got %+v", report)
+	default:
+	}
+}
@@ -0,0 +1,448 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MIMEJSON        = "application/json"
+	MIMEHTML        = "text/html"
+	MIMEXML         = "application/xml"
+	MIMEXML2        = "text/xml"
+	MIMEPlain       = "text/plain"
+	MIMEYAML        = "application/x-yaml"
+	MIMEPROTOBUF    = "application/x-protobuf"
+	MIMEJavaScript  = "application/javascript"
+	MIMEEventStream = "text/event-stream"
+)
+
+// Render 将数据编码为响应体
+// Context.Render会先把结果写入缓冲区，编码失败时客户端收到的是干净的500响应
+type Render interface {
+	ContentType() string
+	Render(w io.Writer) error
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Render 编码成功后才写入状态码、Content-Type和响应体
+func (c *Context) Render(code int, r Render) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+	if err := r.Render(buf); err != nil {
+		c.Fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.SetHeader("Content-Type", r.ContentType())
+	c.Status(code)
+	if bodyAllowedForStatus(code) {
+		c.Writer.Write(buf.Bytes())
+	}
+}
+
+// bodyAllowedForStatus 1xx、204、304响应不允许携带响应体
+func bodyAllowedForStatus(code int) bool {
+	switch {
+	case code >= 100 && code <= 199:
+		return false
+	case code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, indentedJSONRender{obj})
+}
+
+// SecureJSON 数据为JSON数组时加上前缀，防止JSON劫持，前缀可通过Engine.SecureJSONPrefix修改
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, secureJSONRender{prefix: c.engine.secureJSONPrefix, data: obj})
+}
+
+// JSONP 请求带有callback查询参数时以JavaScript函数调用的形式返回，否则等同于JSON
+func (c *Context) JSONP(code int, obj interface{}) {
+	callback := c.Query("callback")
+	if callback == "" {
+		c.JSON(code, obj)
+		return
+	}
+	c.Render(code, jsonpRender{callback: callback, data: obj})
+}
+
+// AsciiJSON 非ASCII字符转义为\uXXXX
+func (c *Context) AsciiJSON(code int, obj interface{}) {
+	c.Render(code, asciiJSONRender{obj})
+}
+
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, xmlRender{obj})
+}
+
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, yamlRender{obj})
+}
+
+// ProtoBuf obj必须实现proto.Message
+func (c *Context) ProtoBuf(code int, obj interface{}) {
+	c.Render(code, protoBufRender{obj})
+}
+
+type textRender struct {
+	format string
+	values []interface{}
+}
+
+func (r textRender) ContentType() string {
+	return MIMEPlain + "; charset=utf-8"
+}
+
+func (r textRender) Render(w io.Writer) error {
+	if len(r.values) == 0 {
+		_, err := io.WriteString(w, r.format)
+		return err
+	}
+	_, err := fmt.Fprintf(w, r.format, r.values...)
+	return err
+}
+
+type jsonRender struct {
+	data interface{}
+}
+
+func (r jsonRender) ContentType() string {
+	return MIMEJSON + "; charset=utf-8"
+}
+
+func (r jsonRender) Render(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r.data)
+}
+
+type indentedJSONRender struct {
+	data interface{}
+}
+
+func (r indentedJSONRender) ContentType() string {
+	return MIMEJSON + "; charset=utf-8"
+}
+
+func (r indentedJSONRender) Render(w io.Writer) error {
+	data, err := json.MarshalIndent(r.data, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type secureJSONRender struct {
+	prefix string
+	data   interface{}
+}
+
+func (r secureJSONRender) ContentType() string {
+	return MIMEJSON + "; charset=utf-8"
+}
+
+func (r secureJSONRender) Render(w io.Writer) error {
+	data, err := json.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, []byte("[")) && bytes.HasSuffix(data, []byte("]")) {
+		if _, err = io.WriteString(w, r.prefix); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type jsonpRender struct {
+	callback string
+	data     interface{}
+}
+
+func (r jsonpRender) ContentType() string {
+	return MIMEJavaScript + "; charset=utf-8"
+}
+
+func (r jsonpRender) Render(w io.Writer) error {
+	data, err := json.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s(%s);", template.JSEscapeString(r.callback), data)
+	return err
+}
+
+type asciiJSONRender struct {
+	data interface{}
+}
+
+func (r asciiJSONRender) ContentType() string {
+	return MIMEJSON
+}
+
+func (r asciiJSONRender) Render(w io.Writer) error {
+	data, err := json.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, ch := range string(data) {
+		if ch < 128 {
+			buf.WriteRune(ch)
+			continue
+		}
+		for _, u := range utf16.Encode([]rune{ch}) {
+			buf.WriteString(`\u`)
+			buf.WriteString(fmt.Sprintf("%04x", u))
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+type xmlRender struct {
+	data interface{}
+}
+
+func (r xmlRender) ContentType() string {
+	return MIMEXML + "; charset=utf-8"
+}
+
+func (r xmlRender) Render(w io.Writer) error {
+	return xml.NewEncoder(w).Encode(r.data)
+}
+
+type yamlRender struct {
+	data interface{}
+}
+
+func (r yamlRender) ContentType() string {
+	return MIMEYAML + "; charset=utf-8"
+}
+
+func (r yamlRender) Render(w io.Writer) error {
+	data, err := yaml.Marshal(r.data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type protoBufRender struct {
+	data interface{}
+}
+
+func (r protoBufRender) ContentType() string {
+	return MIMEPROTOBUF
+}
+
+func (r protoBufRender) Render(w io.Writer) error {
+	msg, ok := r.data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gee: %T does not implement proto.Message", r.data)
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+type htmlRender struct {
+	templates *template.Template
+	name      string
+	data      interface{}
+	// 取得模板时的错误，如模板不存在或热加载解析失败
+	err error
+}
+
+func (r htmlRender) ContentType() string {
+	return MIMEHTML + "; charset=utf-8"
+}
+
+func (r htmlRender) Render(w io.Writer) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.templates == nil {
+		return errors.New("gee: html templates are not loaded")
+	}
+	return r.templates.ExecuteTemplate(w, r.name, r.data)
+}
+
+// SSEvent 一条Server-Sent Event，Data为字符串或[]byte时原样输出，其他类型编码为JSON
+type SSEvent struct {
+	ID    string
+	Event string
+	Retry uint
+	Data  interface{}
+}
+
+func (e SSEvent) ContentType() string {
+	return MIMEEventStream
+}
+
+func (e SSEvent) Render(w io.Writer) error {
+	var buf bytes.Buffer
+	if e.ID != "" {
+		buf.WriteString("id: " + sseEscaper.Replace(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		buf.WriteString("event: " + sseEscaper.Replace(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatUint(uint64(e.Retry), 10) + "\n")
+	}
+	var data string
+	switch v := e.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(b)
+	}
+	// 多行数据拆成多个data字段，客户端会用换行重新拼接
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// id和event字段中不能出现换行
+var sseEscaper = strings.NewReplacer("\n", "\\n", "\r", "\\r")
+
+// Negotiate 按Accept头选择响应格式，各格式未单独提供数据时使用Data
+type Negotiate struct {
+	Offered  []string
+	HTMLName string
+	HTMLData interface{}
+	JSONData interface{}
+	XMLData  interface{}
+	YAMLData interface{}
+	Data     interface{}
+}
+
+// Negotiate 按Accept头在config.Offered中选择格式并渲染，没有可接受的格式时返回406
+func (c *Context) Negotiate(code int, config Negotiate) {
+	pick := func(data interface{}) interface{} {
+		if data != nil {
+			return data
+		}
+		return config.Data
+	}
+	switch c.NegotiateFormat(config.Offered...) {
+	case MIMEJSON:
+		c.JSON(code, pick(config.JSONData))
+	case MIMEHTML:
+		c.HTML(code, config.HTMLName, pick(config.HTMLData))
+	case MIMEXML, MIMEXML2:
+		c.XML(code, pick(config.XMLData))
+	case MIMEYAML:
+		c.YAML(code, pick(config.YAMLData))
+	case MIMEPROTOBUF:
+		c.ProtoBuf(code, config.Data)
+	case MIMEPlain:
+		c.String(code, "%v", config.Data)
+	default:
+		c.AbortWithStatus(http.StatusNotAcceptable)
+	}
+}
+
+// NegotiateFormat 按Accept头中的q值选择offered中最合适的格式，没有Accept头时返回第一个
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		return ""
+	}
+	accepted := parseAccept(c.Req.Header.Get("Accept"))
+	if len(accepted) == 0 {
+		return offered[0]
+	}
+	for _, accept := range accepted {
+		for _, offer := range offered {
+			if mimeMatch(accept, offer) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// parseAccept 解析Accept头，按q值从高到低排列，q=0的类型被排除
+func parseAccept(header string) []string {
+	type item struct {
+		mime string
+		q    float64
+	}
+	var items []item
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		mime := strings.TrimSpace(fields[0])
+		if mime == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			items = append(items, item{mime, q})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+	res := make([]string, len(items))
+	for i, it := range items {
+		res[i] = it.mime
+	}
+	return res
+}
+
+// mimeMatch 支持"*/*"和"text/*"形式的通配
+func mimeMatch(accept, offer string) bool {
+	if accept == "*/*" || accept == offer {
+		return true
+	}
+	if strings.HasSuffix(accept, "/*") {
+		return strings.HasPrefix(offer, accept[:len(accept)-1])
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderers(t *testing.T) {
+	r := New()
+	data := H{"name": "地鼠"}
+	r.GET("/xml", func(c *Context) {
+		c.XML(http.StatusOK, struct {
+			XMLName struct{} `xml:"user"`
+			Name    string   `xml:"name"`
+		}{Name: "gopher"})
+	})
+	r.GET("/yaml", func(c *Context) { c.YAML(http.StatusOK, data) })
+	r.GET("/ascii", func(c *Context) { c.AsciiJSON(http.StatusOK, data) })
+	r.GET("/secure", func(c *Context) { c.SecureJSON(http.StatusOK, []int{1, 2}) })
+	r.GET("/jsonp", func(c *Context) { c.JSONP(http.StatusOK, data) })
+	r.GET("/sse", func(c *Context) {
+		c.Render(http.StatusOK, SSEvent{ID: "1", Event: "message", Data: "a\nb"})
+	})
+
+	cases := map[string]string{
+		"/xml":               "<user><name>gopher</name></user>",
+		"/yaml":              "name: 地鼠\n",
+		"/ascii":             `{"name":"\u5730\u9f20"}`,
+		"/secure":            "while(1);[1,2]",
+		"/jsonp?callback=cb": `cb({"name":"地鼠"});`,
+		"/sse":               "id: 1\nevent: message\ndata: a\ndata: b\n\n",
+	}
+	for path, expect := range cases {
+		if w := performRequest(r, http.MethodGet, path); w.Body.String() != expect {
+			t.Fatalf("%s expect %q, got %q", path, expect, w.Body.String())
+		}
+	}
+}
+
+func TestRenderErrorBecomesCleanFailure(t *testing.T) {
+	r := New()
+	r.GET("/bad", func(c *Context) {
+		c.JSON(http.StatusOK, H{"ch": make(chan int)})
+	})
+	w := performRequest(r, http.MethodGet, "/bad")
+	if w.Code != http.StatusInternalServerError || !strings.HasPrefix(w.Body.String(), `{"message":`) {
+		t.Fatalf("expect a single clean 500, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	r := New()
+	r.GET("/user", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{MIMEJSON, MIMEXML, MIMEYAML},
+			Data:    H{"name": "gopher"},
+			XMLData: struct {
+				XMLName struct{} `xml:"user"`
+			}{},
+		})
+	})
+	cases := map[string]string{
+		"":                                    MIMEJSON,
+		"application/xml":                     MIMEXML,
+		"text/html, application/x-yaml;q=0.9": MIMEYAML,
+		"application/json;q=0.5, */*;q=0.8":   MIMEJSON,
+		"application/json;q=0.9, application/xml": MIMEXML,
+	}
+	for accept, expect := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), expect) {
+			t.Fatalf("Accept %q expect %s, got %s", accept, expect, w.Header().Get("Content-Type"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/user", nil)
+	req.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expect 406, got %d", w.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriterTracksStatusAndSize(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &responseWriter{}
+	w.reset(rec)
+	if w.Written() || w.Status() != http.StatusOK || w.Size() != -1 {
+		t.Fatalf("unexpected initial state %d %d", w.Status(), w.Size())
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusAccepted)
+	if rec.Code != http.StatusOK || w.Written() {
+		t.Fatal("status should not be sent before the first write")
+	}
+	_, _ = w.Write([]byte("hello"))
+	_, _ = w.WriteString(" gee")
+	w.WriteHeader(http.StatusInternalServerError)
+	if rec.Code != http.StatusAccepted || w.Status() != http.StatusAccepted || w.Size() != 9 {
+		t.Fatalf("unexpected state %d %d %d", rec.Code, w.Status(), w.Size())
+	}
+	w.Flush()
+	if !rec.Flushed {
+		t.Fatal("flush should reach the underlying writer")
+	}
+	if err := w.Push("/style.css", nil); err != http.ErrNotSupported {
+		t.Fatalf("expect ErrNotSupported, got %v", err)
+	}
+}
+
+func TestPanicAfterPartialWrite(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	var status, size int
+	r.Use(func(c *Context) {
+		c.Next()
+		status, size = c.Writer.Status(), c.Writer.Size()
+	})
+	r.GET("/partial", func(c *Context) {
+		c.Writer.WriteHeader(http.StatusPartialContent)
+		_, _ = c.Writer.Write([]byte("part"))
+		panic("boom")
+	})
+	r.GET("/empty", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	// 响应已经发出，Recovery不再改写，而是以http.ErrAbortHandler让net/http断开连接
+	w := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Fatalf("want http.ErrAbortHandler, got %v", err)
+			}
+		}()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/partial", nil))
+	}()
+	if w.Code != http.StatusPartialContent || w.Body.String() != "part" {
+		t.Fatalf("recovery should not touch a written response, got %d %q", w.Code, w.Body.String())
+	}
+	if w = performRequest(r, http.MethodGet, "/empty"); w.Code != http.StatusNoContent || status != http.StatusNoContent || size != -1 {
+		t.Fatalf("status without body should still be sent, got %d %d %d", w.Code, status, size)
+	}
+}
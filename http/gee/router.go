@@ -3,68 +3,118 @@ package gee
 import (
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 type router struct {
+	// 一种请求方法对应一棵前缀树，处理链挂在路由节点上
 	roots map[string]*node
-	handlers map[string]HandlerFunc
+	// 所有路由中路径参数的最大个数，用于预分配Context中的参数切片
+	maxParams int
 }
 
 func newRouter() *router {
 	return &router{
 		roots: make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
 	}
 }
 
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
-	log.Printf("Route %4s - %s", method, pattern)
+// addRoute 新增路由，返回挂载处理链的路由节点
+// pattern会被规范化：去掉多余和结尾的'/'，通配段之后的内容被忽略
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) *node {
 	parts := parsePattern(pattern)
+	pattern = "/" + strings.Join(parts, "/")
+	log.Printf("Route %4s - %s", method, pattern)
 	_, ok := r.roots[method]
 	if !ok {
 		r.roots[method] = &node{}
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	key := method + "-" + pattern
-	r.handlers[key] = handler
+	n := r.roots[method].insert(pattern, handlers)
+	params := 0
+	for _, part := range parts {
+		if part[0] == ':' || (part[0] == '*' && len(part) > 1) {
+			params++
+		}
+	}
+	if params > r.maxParams {
+		r.maxParams = params
+	}
+	return n
 }
-func (r *router) getRoute(method, path string) (*node, map[string]string) {
+
+// find 匹配路由，参数写入params，匹配失败时params保持不变
+// 路径结尾多余的'/'会被忽略，与注册时的规范化保持一致
+func (r *router) find(method, path string, params *Params) *node {
 	root, ok := r.roots[method]
 	if !ok {
-		return nil, nil
+		return nil
 	}
-	params := make(map[string]string)
-	searchParts := parsePattern(path)
-	n := root.search(searchParts, 0)
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for i, v := range parts {
-			if v[0] == ':' {
-				params[v[1:]] = searchParts[i]
-			}
-			if v[0] == '*' && len(v) > 1{
-				params[v[1:]] = strings.Join(searchParts[i:], "/")
-				break
-			}
-		}
+	n := root.search(path, params)
+	if n == nil && len(path) > 1 && path[len(path)-1] == '/' {
+		n = root.search(path[:len(path)-1], params)
+	}
+	return n
+}
+
+func (r *router) getRoute(method, path string) (*node, Params) {
+	params := make(Params, 0, r.maxParams)
+	if n := r.find(method, path, &params); n != nil {
 		return n, params
 	}
 	return nil, nil
 }
 
-func (r *router) handler(c *Context) {
-	n, params := r.getRoute(c.Method, c.Path)
-	if n != nil {
-		c.Params = params
-		key := c.Method + "-" + n.pattern
-		c.handlers = append(c.handlers, r.handlers[key])
-	}else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+// handle 匹配成功时执行处理链并返回true，c.Params中已有的参数会保留
+func (r *router) handle(c *Context) bool {
+	n := r.find(c.Method, c.Path, &c.Params)
+	if n == nil {
+		return false
 	}
+	c.fullPath = n.pattern
+	c.handlers = n.handlers
 	c.Next()
+	return true
+}
+
+// handleUnmatched 未命中路由时，自动应答OPTIONS或按405、404处理
+func (r *router) handleUnmatched(c *Context) {
+	allow, other := r.allowed(c.Method, c.Path)
+	switch {
+	case other != nil && c.Method == http.MethodOptions && c.engine.HandleOPTIONS:
+		// 未注册OPTIONS路由时自动应答，同样经过该路径所属分组的中间件，CORS等中间件可以处理预检请求
+		c.SetHeader("Allow", strings.Join(append(allow, http.MethodOptions), ", "))
+		c.handlers = other.optionsHandlers
+	case other != nil && c.engine.HandleMethodNotAllowed:
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		c.handlers = c.engine.allNoMethod
+	default:
+		c.handlers = c.engine.allNoRoute
+	}
+	c.Next()
+}
+
+// allowed 返回除当前方法外，能匹配该路径的其他请求方法，以及按方法名排序后第一个匹配到的路由节点
+// 自动应答OPTIONS时使用该节点所属分组的中间件，按固定顺序选择保证每次结果一致
+func (r *router) allowed(method, path string) ([]string, *node) {
+	methods := make([]string, 0, len(r.roots))
+	for m := range r.roots {
+		if m != method {
+			methods = append(methods, m)
+		}
+	}
+	sort.Strings(methods)
+	allow := make([]string, 0, len(methods))
+	var matched *node
+	for _, m := range methods {
+		if n, _ := r.getRoute(m, path); n != nil {
+			allow = append(allow, m)
+			if matched == nil {
+				matched = n
+			}
+		}
+	}
+	return allow, matched
 }
 
 func parsePattern(pattern string) []string {
@@ -79,4 +129,4 @@ func parsePattern(pattern string) []string {
 		}
 	}
 	return parts
-}
\ No newline at end of file
+}
@@ -0,0 +1,178 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// trieNode 是换成压缩前缀树之前按路径段切分的前缀树，仅作为基准测试的对照
+type trieNode struct {
+	pattern  string
+	part     string
+	isWild   bool
+	children []*trieNode
+}
+
+func (n *trieNode) matchChild(part string) *trieNode {
+	for _, child := range n.children {
+		if child.part == part {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *trieNode) matchChildren(part string) []*trieNode {
+	res := make([]*trieNode, 0)
+	for _, child := range n.children {
+		if child.part == part || child.isWild {
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+func (n *trieNode) insert(pattern string, parts []string, height int) {
+	if len(parts) == height {
+		n.pattern = pattern
+		return
+	}
+	part := parts[height]
+	child := n.matchChild(part)
+	if child == nil {
+		child = &trieNode{part: part, isWild: part[0] == ':' || part[0] == '*'}
+		n.children = append(n.children, child)
+	}
+	child.insert(pattern, parts, height+1)
+}
+
+func (n *trieNode) search(parts []string, height int) *trieNode {
+	if height == len(parts) || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+	for _, child := range n.matchChildren(parts[height]) {
+		if result := child.search(parts, height+1); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// getRoute 与旧版router.getRoute相同：每次请求切分路径并构造参数map
+func (n *trieNode) getRoute(path string) (*trieNode, map[string]string) {
+	searchParts := parsePattern(path)
+	result := n.search(searchParts, 0)
+	if result == nil {
+		return nil, nil
+	}
+	params := make(map[string]string)
+	for i, v := range parsePattern(result.pattern) {
+		if v[0] == ':' {
+			params[v[1:]] = searchParts[i]
+		}
+		if v[0] == '*' && len(v) > 1 {
+			params[v[1:]] = strings.Join(searchParts[i:], "/")
+			break
+		}
+	}
+	return result, params
+}
+
+var benchRoutes = []string{
+	"/",
+	"/users",
+	"/users/:id",
+	"/users/:id/profile",
+	"/users/:id/repos/:repo",
+	"/users/:id/repos/:repo/issues",
+	"/orgs/:org/members",
+	"/orgs/:org/teams/:team",
+	"/search/code",
+	"/search/issues",
+	"/static/*filepath",
+}
+
+var benchPaths = []string{
+	"/",
+	"/users/42/profile",
+	"/users/42/repos/gee/issues",
+	"/orgs/geektutu/teams/core",
+	"/search/issues",
+	"/static/css/site.css",
+}
+
+func BenchmarkTrieRouter(b *testing.B) {
+	root := &trieNode{}
+	for _, pattern := range benchRoutes {
+		root.insert(pattern, parsePattern(pattern), 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			if n, _ := root.getRoute(path); n == nil {
+				b.Fatalf("%s not matched", path)
+			}
+		}
+	}
+}
+
+func BenchmarkRadixRouter(b *testing.B) {
+	r := newRouter()
+	for _, pattern := range benchRoutes {
+		r.addRoute("GET", pattern, nil)
+	}
+	params := make(Params, 0, r.maxParams)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, path := range benchPaths {
+			params = params[:0]
+			if n := r.find("GET", path, &params); n == nil {
+				b.Fatalf("%s not matched", path)
+			}
+		}
+	}
+}
+
+func BenchmarkEngineServeHTTP(b *testing.B) {
+	r := New()
+	for _, pattern := range benchRoutes {
+		r.GET(pattern, func(c *Context) {})
+	}
+	w := &benchResponseWriter{}
+	reqs := make([]*http.Request, len(benchPaths))
+	for i, path := range benchPaths {
+		reqs[i] = httptest.NewRequest(http.MethodGet, path, nil)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, req := range reqs {
+			r.ServeHTTP(w, req)
+		}
+	}
+}
+
+// benchResponseWriter 丢弃所有输出，避免ResponseRecorder的开销影响结果
+type benchResponseWriter struct {
+	header http.Header
+}
+
+func (w *benchResponseWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+
+func (w *benchResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *benchResponseWriter) WriteHeader(int) {}
@@ -35,10 +35,120 @@ func TestGetRoute(t *testing.T) {
 		t.Fatal("should match /hello/:name")
 	}
 
-	if ps["name"] != "geektutu" {
+	if ps.ByName("name") != "geektutu" {
 		t.Fatal("name should be equal to 'geektutu'")
 	}
 
-	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps["name"])
+	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps.ByName("name"))
 
-}
\ No newline at end of file
+}
+
+func TestRoutePriority(t *testing.T) {
+	r := newRouter()
+	r.addRoute("GET", "/users/*path", nil)
+	r.addRoute("GET", "/users/:id", nil)
+	r.addRoute("GET", "/users/me", nil)
+	r.addRoute("GET", "/users/:id/profile", nil)
+
+	cases := map[string]string{
+		"/users/me":         "/users/me",
+		"/users/42":         "/users/:id",
+		"/users/me/profile": "/users/:id/profile",
+		"/users/42/a/b":     "/users/*path",
+	}
+	for path, pattern := range cases {
+		n, _ := r.getRoute("GET", path)
+		if n == nil || n.pattern != pattern {
+			t.Fatalf("%s should match %s, got %v", path, pattern, n)
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	conflicts := [][2]string{
+		{"/users/:id", "/users/:name"},
+		{"/users/:id", "/users/:id"},
+		{"/files/*path", "/files/*name"},
+	}
+	for _, routes := range conflicts {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("registering %s after %s should panic", routes[1], routes[0])
+				}
+			}()
+			r := newRouter()
+			r.addRoute("GET", routes[0], nil)
+			r.addRoute("GET", routes[1], nil)
+		}()
+	}
+}
+
+func listUsers(c *Context) {}
+
+func TestEngineRoutes(t *testing.T) {
+	r := New()
+	r.POST("/users", listUsers)
+	r.GET("/users/:id", listUsers)
+	r.GET("/users/me", listUsers)
+
+	routes := r.Routes()
+	expect := []string{"GET /users/me", "GET /users/:id", "POST /users"}
+	if len(routes) != len(expect) {
+		t.Fatalf("expect %d routes, got %v", len(expect), routes)
+	}
+	for i, info := range routes {
+		if info.Method+" "+info.Path != expect[i] || info.Handler != "http_learn/gee.listUsers" {
+			t.Fatalf("unexpected route %d: %+v", i, info)
+		}
+	}
+}
+
+func TestRadixTreeMatch(t *testing.T) {
+	r := newRouter()
+	r.addRoute("GET", "/src/*filepath", nil)
+	r.addRoute("GET", "/search/", nil)
+	r.addRoute("GET", "/support", nil)
+	r.addRoute("GET", "/cmd/:tool/:sub", nil)
+	r.addRoute("GET", "/cmd/:tool/", nil)
+	contact := r.addRoute("GET", "/contact", nil)
+	r.addRoute("GET", "/co", nil)
+	r.addRoute("GET", "/user_:name", nil)
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/src/some/file.png", "/src/*filepath", Params{{"filepath", "some/file.png"}}},
+		{"/search", "/search", nil},
+		{"/search/", "/search", nil},
+		{"/support", "/support", nil},
+		{"/cmd/test/", "/cmd/:tool", Params{{"tool", "test"}}},
+		{"/cmd/test/3", "/cmd/:tool/:sub", Params{{"tool", "test"}, {"sub", "3"}}},
+		{"/co", "/co", nil},
+		{"/user_:name", "/user_:name", nil},
+		{"/user_gopher", "", nil},
+		{"/src/", "", nil},
+		{"/cmd//", "", nil},
+	}
+	for _, tc := range cases {
+		n, ps := r.getRoute("GET", tc.path)
+		if tc.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s should not match, got %s", tc.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != tc.pattern {
+			t.Fatalf("%s should match %s, got %v", tc.path, tc.pattern, n)
+		}
+		if len(ps) != len(tc.params) || (len(ps) > 0 && !reflect.DeepEqual(ps, tc.params)) {
+			t.Fatalf("%s params expect %v, got %v", tc.path, tc.params, ps)
+		}
+	}
+	// 后续注册引起的节点分裂不能让之前返回的路由节点失效
+	if n, _ := r.getRoute("GET", "/contact"); n != contact {
+		t.Fatal("route node changed after splitting")
+	}
+}
@@ -0,0 +1,220 @@
+package gee
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// routeScope 一组共用同一棵前缀树的分组，附加了条件的作用域只处理满足全部条件的请求
+// 默认作用域没有条件，其他作用域都未命中路由时由它处理
+type routeScope struct {
+	router *router
+	groups []*RouterGroup
+	// 请求需满足的条件，包括从上级作用域继承的条件
+	matchers []requestMatcher
+	// 创建该作用域的分组，它在上级作用域中适用的中间件排在本作用域的中间件之前
+	parent *RouterGroup
+}
+
+func newRouteScope(parent *RouterGroup, matchers []requestMatcher) *routeScope {
+	return &routeScope{router: newRouter(), parent: parent, matchers: matchers}
+}
+
+// match 检查请求是否满足全部条件，Host中的参数写入c.Params
+func (s *routeScope) match(c *Context) bool {
+	for _, m := range s.matchers {
+		if !m.match(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// middlewaresFor 作用于pattern的全部中间件，上级作用域的在前，同一作用域内按前缀层级由浅到深
+func (s *routeScope) middlewaresFor(pattern string) []HandlerFunc {
+	var merged []HandlerFunc
+	if s.parent != nil {
+		merged = append(merged, s.parent.scope.middlewaresFor(s.parent.prefix)...)
+	}
+	for _, group := range s.groups {
+		if matchGroupPrefix(pattern, group.prefix) {
+			merged = append(merged, group.middlewares...)
+		}
+	}
+	return merged
+}
+
+// wildcards Host条件中":name"和"*"段的个数
+func (s *routeScope) wildcards() int {
+	n := 0
+	for _, m := range s.matchers {
+		if h, ok := m.(hostMatcher); ok {
+			for _, label := range h.labels {
+				if label[0] == ':' || label == "*" {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// condition 用于Routes展示的条件描述，默认作用域为空
+func (s *routeScope) condition() string {
+	conds := make([]string, len(s.matchers))
+	for i, m := range s.matchers {
+		conds[i] = m.String()
+	}
+	return strings.Join(conds, " && ")
+}
+
+// requestMatcher 作用域的一个条件
+type requestMatcher interface {
+	match(c *Context) bool
+	String() string
+}
+
+// Host 返回只匹配指定域名的分组，它有自己的前缀树和中间件，并继承当前分组的前缀、条件和中间件
+// pattern按'.'分段，":name"匹配任意一段并可通过c.Param("name")读取，"*"匹配任意一段，
+// 如":tenant.example.com"；比较时忽略大小写和端口
+func (g *RouterGroup) Host(pattern string) *RouterGroup {
+	return g.newScope(newHostMatcher(pattern))
+}
+
+// Header 返回只匹配请求头key等于value的分组，如Header("Accept-Version", "v2")，value为空时只要求请求头存在
+func (g *RouterGroup) Header(key, value string) *RouterGroup {
+	if key == "" {
+		panic("gee: header routing requires a header name")
+	}
+	return g.newScope(headerMatcher{key: http.CanonicalHeaderKey(key), value: value})
+}
+
+// Match 返回只匹配fn返回true的请求的分组，用于域名和请求头以外的条件
+func (g *RouterGroup) Match(fn func(req *http.Request) bool) *RouterGroup {
+	if fn == nil {
+		panic("gee: route predicate must not be nil")
+	}
+	return g.newScope(predicateMatcher(fn))
+}
+
+func (g *RouterGroup) newScope(m requestMatcher) *RouterGroup {
+	e := g.engine
+	matchers := append(append([]requestMatcher{}, g.scope.matchers...), m)
+	s := newRouteScope(g, matchers)
+	group := &RouterGroup{
+		engine: e,
+		prefix: g.prefix,
+		parent: g,
+		scope:  s,
+	}
+	s.groups = []*RouterGroup{group}
+	// 条件越多越具体，优先尝试；条件个数相同时Host中通配段少的优先，再按创建顺序
+	scopes := append(e.scopes, s)
+	sort.SliceStable(scopes, func(i, j int) bool {
+		if len(scopes[i].matchers) != len(scopes[j].matchers) {
+			return len(scopes[i].matchers) > len(scopes[j].matchers)
+		}
+		return scopes[i].wildcards() < scopes[j].wildcards()
+	})
+	e.scopes = scopes
+	return group
+}
+
+// hostMatcher 按'.'分段匹配请求的Host
+type hostMatcher struct {
+	pattern string
+	labels  []string
+}
+
+func newHostMatcher(pattern string) hostMatcher {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	if pattern == "" {
+		panic("gee: host pattern must not be empty")
+	}
+	labels := strings.Split(pattern, ".")
+	for _, label := range labels {
+		if label == "" || label == ":" {
+			panic("gee: invalid host pattern " + pattern)
+		}
+	}
+	return hostMatcher{pattern: pattern, labels: labels}
+}
+
+func (m hostMatcher) match(c *Context) bool {
+	host := requestHost(c.Req)
+	n := len(c.Params)
+	for i, label := range m.labels {
+		var part string
+		if i == len(m.labels)-1 {
+			part, host = host, ""
+		} else {
+			dot := strings.IndexByte(host, '.')
+			if dot < 0 {
+				c.Params = c.Params[:n]
+				return false
+			}
+			part, host = host[:dot], host[dot+1:]
+		}
+		switch {
+		case part == "":
+			c.Params = c.Params[:n]
+			return false
+		case label[0] == ':':
+			c.Params = append(c.Params, Param{Key: label[1:], Value: part})
+		case label == "*":
+		case !strings.EqualFold(label, part):
+			c.Params = c.Params[:n]
+			return false
+		}
+	}
+	return true
+}
+
+func (m hostMatcher) String() string {
+	return "Host=" + m.pattern
+}
+
+// requestHost 去掉端口和结尾的'.'，IPv6地址保留方括号
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if i := strings.LastIndexByte(host, ':'); i > strings.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+type headerMatcher struct {
+	key   string
+	value string
+}
+
+func (m headerMatcher) match(c *Context) bool {
+	values := c.Req.Header[m.key]
+	if m.value == "" {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if strings.TrimSpace(v) == m.value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m headerMatcher) String() string {
+	if m.value == "" {
+		return m.key
+	}
+	return m.key + "=" + m.value
+}
+
+type predicateMatcher func(req *http.Request) bool
+
+func (m predicateMatcher) match(c *Context) bool {
+	return m(c.Req)
+}
+
+func (m predicateMatcher) String() string {
+	return "Match(" + nameOfFunction(m) + ")"
+}
@@ -0,0 +1,102 @@
+package gee
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.Writer.Header().Add("X-Trace", "global")
+		c.Next()
+	})
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "main") })
+
+	tenant := r.Host(":tenant.example.com")
+	tenant.Use(func(c *Context) {
+		c.Writer.Header().Add("X-Trace", "tenant")
+		c.Next()
+	})
+	tenant.GET("/", func(c *Context) { c.String(http.StatusOK, "tenant %s", c.Param("tenant")) })
+	tenant.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s/%s", c.Param("tenant"), c.Param("id"))
+	})
+	r.Host("api.example.com").GET("/", func(c *Context) { c.String(http.StatusOK, "api") })
+
+	for _, tt := range []struct {
+		host, path, want string
+	}{
+		{"acme.example.com", "/", "tenant acme"},
+		{"ACME.Example.com:8080", "/users/7", "ACME/7"},
+		{"api.example.com", "/", "api"},
+		{"example.com", "/", "main"},
+		{"a.b.example.com", "/", "main"},
+		// 域名匹配但路径未注册时由默认作用域处理
+		{"acme.example.com", "/about", "404 NOT FOUND: /about\n"},
+	} {
+		w := performRequest(r, http.MethodGet, tt.path, withHost(tt.host))
+		if w.Body.String() != tt.want {
+			t.Fatalf("%s%s: want %q, got %q", tt.host, tt.path, tt.want, w.Body.String())
+		}
+	}
+	// 分组的中间件只作用于自己的路由，全局中间件作用于所有作用域
+	if got := performRequest(r, http.MethodGet, "/", withHost("acme.example.com")).Header()["X-Trace"]; strings.Join(got, ",") != "global,tenant" {
+		t.Fatalf("unexpected middleware chain %v", got)
+	}
+	if got := performRequest(r, http.MethodGet, "/", withHost("example.com")).Header()["X-Trace"]; strings.Join(got, ",") != "global" {
+		t.Fatalf("tenant middleware should not run for the main host, got %v", got)
+	}
+	if w := performRequest(r, http.MethodPost, "/users/7", withHost("acme.example.com")); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET" {
+		t.Fatalf("want 405 from the tenant scope, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestHeaderAndPredicateRouting(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.Use(func(c *Context) {
+		c.Set("api", true)
+		c.Next()
+	})
+	api.GET("/items", func(c *Context) { c.String(http.StatusOK, "v1") })
+	v2 := api.Header("Accept-Version", "v2")
+	v2.GET("/items", func(c *Context) { c.String(http.StatusOK, "v2 %v", c.GetBool("api")) })
+	// 条件更多的分组优先
+	v2.Host("beta.example.com").GET("/items", func(c *Context) { c.String(http.StatusOK, "v2 beta") })
+	r.Match(func(req *http.Request) bool {
+		return req.URL.Query().Get("preview") == "1"
+	}).GET("/api/items", func(c *Context) { c.String(http.StatusOK, "preview") })
+
+	for _, tt := range []struct {
+		host, path, version string
+		want                string
+	}{
+		{"example.com", "/api/items", "", "v1"},
+		{"example.com", "/api/items", "v2", "v2 true"},
+		{"example.com", "/api/items", "v3", "v1"},
+		{"beta.example.com", "/api/items", "v2", "v2 beta"},
+		{"beta.example.com", "/api/items", "", "v1"},
+		{"example.com", "/api/items?preview=1", "", "preview"},
+	} {
+		opts := []requestOption{withHost(tt.host)}
+		if tt.version != "" {
+			opts = append(opts, withHeader("Accept-Version", tt.version))
+		}
+		w := performRequest(r, http.MethodGet, tt.path, opts...)
+		if w.Body.String() != tt.want {
+			t.Fatalf("%s%s version %q: want %q, got %q", tt.host, tt.path, tt.version, tt.want, w.Body.String())
+		}
+	}
+
+	conditions := make(map[string]bool)
+	for _, info := range r.Routes() {
+		conditions[info.Condition] = true
+	}
+	for _, want := range []string{"", "Accept-Version=v2", "Accept-Version=v2 && Host=beta.example.com"} {
+		if !conditions[want] {
+			t.Fatalf("Routes should report condition %q, got %v", want, conditions)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+)
+
+// Run 在addr上监听并处理请求，阻塞直到出错或被Shutdown关闭，正常关闭时返回nil
+func (e *Engine) Run(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.serve(l, func(srv *http.Server) error {
+		return srv.Serve(l)
+	})
+}
+
+// RunTLS 同Run，使用HTTPS
+func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.serve(l, func(srv *http.Server) error {
+		return srv.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+// RunUnix 在unix socket上处理请求，退出时删除socket文件
+func (e *Engine) RunUnix(file string) error {
+	l, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return e.serve(l, func(srv *http.Server) error {
+		return srv.Serve(l)
+	})
+}
+
+// RunListener 在已有的listener上处理请求
+func (e *Engine) RunListener(l net.Listener) error {
+	return e.serve(l, func(srv *http.Server) error {
+		return srv.Serve(l)
+	})
+}
+
+// RUN 兼容旧接口
+//
+// Deprecated: 使用Run，它会返回监听和服务过程中的错误
+func (e *Engine) RUN(addr string) error {
+	return e.Run(addr)
+}
+
+// OnStart 注册服务器开始接受连接前执行的钩子，参数为实际监听的地址
+func (e *Engine) OnStart(hook func(addr string)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onStart = append(e.onStart, hook)
+}
+
+// OnShutdown 注册Shutdown排空所有请求后执行的钩子，适合关闭数据库连接等清理工作
+func (e *Engine) OnShutdown(hook func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onShutdown = append(e.onShutdown, hook)
+}
+
+// Shutdown 停止接受新连接，等待正在处理的请求结束后返回
+// ctx超时后不再等待，返回ctx的错误，此时仍未结束的请求会被直接断开
+// 之后再调用Run系列方法会直接返回nil，包括与Shutdown同时启动、尚未开始服务的服务器
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.shuttingDown = true
+	servers := e.servers
+	e.servers = nil
+	hooks := e.onShutdown
+	e.mu.Unlock()
+
+	var err error
+	for _, srv := range servers {
+		if shutdownErr := srv.Shutdown(ctx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+	return err
+}
+
+func (e *Engine) newServer() *http.Server {
+	return &http.Server{
+		Handler:           e,
+		ReadTimeout:       e.ReadTimeout,
+		ReadHeaderTimeout: e.ReadHeaderTimeout,
+		WriteTimeout:      e.WriteTimeout,
+		IdleTimeout:       e.IdleTimeout,
+		MaxHeaderBytes:    e.MaxHeaderBytes,
+	}
+}
+
+// serve 登记服务器以便Shutdown找到它，执行OnStart钩子后开始服务
+func (e *Engine) serve(l net.Listener, start func(srv *http.Server) error) error {
+	srv := e.newServer()
+	srv.Addr = l.Addr().String()
+	e.mu.Lock()
+	if e.shuttingDown {
+		e.mu.Unlock()
+		l.Close()
+		return nil
+	}
+	e.servers = append(e.servers, srv)
+	hooks := e.onStart
+	e.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(srv.Addr)
+	}
+	if err := start(srv); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package gee
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	r := New()
+	r.ReadHeaderTimeout = time.Second
+	started := make(chan string, 1)
+	r.OnStart(func(addr string) { started <- addr })
+	shutdown := false
+	r.OnShutdown(func() { shutdown = true })
+
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	r.GET("/slow", func(c *Context) {
+		close(inFlight)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- r.RunListener(l) }()
+	addr := <-started
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-inFlight
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- r.Shutdown(ctx) }()
+	// Shutdown首先关闭listener，之后才放行请求，确认它会等待正在处理的请求
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		runtime.Gosched()
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("shutdown returned before the in-flight request finished: %v", err)
+	default:
+	}
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if b := <-body; b != "done" {
+		t.Fatalf("in-flight request should finish, got %q", b)
+	}
+	if err := <-runErr; err != nil || !shutdown {
+		t.Fatalf("run should return nil after shutdown, got %v, hook called %v", err, shutdown)
+	}
+}
+
+func TestShutdownBeforeServe(t *testing.T) {
+	r := New()
+	started := false
+	r.OnStart(func(string) { started = true })
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已经Shutdown，服务器不应再启动，listener被关闭
+	if err := r.RunListener(l); err != nil || started {
+		t.Fatalf("run after shutdown should return nil without starting, got %v, started %v", err, started)
+	}
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatal("listener should be closed")
+	}
+}
@@ -0,0 +1,206 @@
+package gee
+
+import (
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// StaticConfig 静态文件服务配置，Root、FS、FileSystem三者取其一
+type StaticConfig struct {
+	// 本地目录
+	Root string
+	// 任意fs.FS，包括go:embed生成的embed.FS，可先用fs.Sub去掉目录前缀
+	FS fs.FS
+	// 已有的http.FileSystem
+	FileSystem http.FileSystem
+	// 是否列出没有index.html的目录，关闭时返回404
+	Browse bool
+	// 设置在每个文件响应上的Cache-Control，如"public, max-age=31536000, immutable"
+	CacheControl string
+	// 按文件大小和修改时间生成ETag，配合If-None-Match返回304
+	ETag bool
+	// 客户端支持时优先返回同名的.br、.gz预压缩文件
+	Precompressed bool
+	// 文件不存在且路径没有扩展名时返回该文件，用于前端路由的单页应用，如"/index.html"
+	SPAFallback string
+}
+
+// 预压缩文件的扩展名，按优先顺序排列
+var precompressedEncodings = []string{EncodingBrotli, EncodingGzip}
+
+var precompressedExt = map[string]string{EncodingBrotli: ".br", EncodingGzip: ".gz"}
+
+// Static 把本地目录root挂载到relativePath下，目录没有index.html时列出文件
+func (g *RouterGroup) Static(relativePath string, root string) {
+	g.StaticWithConfig(relativePath, StaticConfig{Root: root, Browse: true})
+}
+
+// StaticFS 使用任意http.FileSystem提供静态文件，目录没有index.html时列出文件
+func (g *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) {
+	g.StaticWithConfig(relativePath, StaticConfig{FileSystem: fs, Browse: true})
+}
+
+// StaticFile 把单个本地文件挂载到relativePath
+func (g *RouterGroup) StaticFile(relativePath, filepath string) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("gee: URL parameters can not be used when serving a static file")
+	}
+	handler := func(c *Context) {
+		c.File(filepath)
+	}
+	g.GET(relativePath, handler)
+	g.HEAD(relativePath, handler)
+}
+
+// StaticWithConfig 按配置把文件系统挂载到relativePath下，同时注册GET和HEAD
+func (g *RouterGroup) StaticWithConfig(relativePath string, conf StaticConfig) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("gee: URL parameters can not be used when serving a static folder")
+	}
+	handler := g.createStaticHandler(relativePath, conf)
+	pattern := path.Join(relativePath, "/*filePath")
+	// 通配段不匹配空路径，根目录单独注册，带不带结尾的'/'都会匹配到它
+	for _, p := range []string{relativePath, pattern} {
+		g.GET(p, handler)
+		g.HEAD(p, handler)
+	}
+}
+
+func (g *RouterGroup) createStaticHandler(relativePath string, conf StaticConfig) HandlerFunc {
+	fsys := conf.FileSystem
+	switch {
+	case fsys != nil:
+	case conf.FS != nil:
+		fsys = http.FS(conf.FS)
+	case conf.Root != "":
+		fsys = http.Dir(conf.Root)
+	default:
+		panic("gee: static file serving requires Root, FS or FileSystem")
+	}
+	absolutePath := path.Join(g.prefix, relativePath)
+	// 目录列表仍交给标准库生成
+	lister := http.StripPrefix(absolutePath, http.FileServer(fsys))
+
+	return func(c *Context) {
+		name := path.Clean("/" + c.Param("filePath"))
+		f, err := fsys.Open(name)
+		if err != nil {
+			if fallback := conf.SPAFallback; fallback != "" && path.Ext(name) == "" {
+				serveStaticFile(c, fsys, path.Clean("/"+fallback), conf)
+				return
+			}
+			c.String(http.StatusNotFound, "404 page not found")
+			return
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil {
+			c.String(http.StatusNotFound, "404 page not found")
+			return
+		}
+		if !info.IsDir() {
+			serveStaticFile(c, fsys, name, conf)
+			return
+		}
+
+		// 目录：统一使用以'/'结尾的地址，保证页面中的相对路径正确
+		if !strings.HasSuffix(c.Req.URL.Path, "/") {
+			target := c.Req.URL.Path + "/"
+			if c.Req.URL.RawQuery != "" {
+				target += "?" + c.Req.URL.RawQuery
+			}
+			http.Redirect(c.Writer, c.Req, target, http.StatusMovedPermanently)
+			return
+		}
+		index := path.Join(name, "index.html")
+		if idx, err := fsys.Open(index); err == nil {
+			idx.Close()
+			serveStaticFile(c, fsys, index, conf)
+			return
+		}
+		if conf.Browse {
+			lister.ServeHTTP(c.Writer, c.Req)
+			return
+		}
+		c.String(http.StatusNotFound, "404 page not found")
+	}
+}
+
+// serveStaticFile 发送单个文件，由http.ServeContent处理Range、If-Modified-Since和If-None-Match
+func serveStaticFile(c *Context, fsys http.FileSystem, name string, conf StaticConfig) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		c.String(http.StatusNotFound, "404 page not found")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.String(http.StatusNotFound, "404 page not found")
+		return
+	}
+
+	header := c.Writer.Header()
+	content, contentInfo := f, info
+	if conf.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		if encoding, sidecar, sidecarInfo := openPrecompressed(c, fsys, name); sidecar != nil {
+			defer sidecar.Close()
+			// Content-Type按原文件确定，不能让ServeContent按.gz/.br嗅探
+			if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+				header.Set("Content-Type", ctype)
+			} else {
+				header.Set("Content-Type", "application/octet-stream")
+			}
+			header.Set("Content-Encoding", encoding)
+			content, contentInfo = sidecar, sidecarInfo
+		}
+	}
+	if conf.CacheControl != "" {
+		header.Set("Cache-Control", conf.CacheControl)
+	}
+	if conf.ETag {
+		etag := strconv.FormatInt(contentInfo.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(contentInfo.Size(), 36)
+		if encoding := header.Get("Content-Encoding"); encoding != "" {
+			etag += "-" + encoding
+		}
+		header.Set("ETag", `"`+etag+`"`)
+	}
+	// 预压缩文件的修改时间与原文件不一定一致，以原文件为准
+	http.ServeContent(c.Writer, c.Req, info.Name(), info.ModTime(), content)
+}
+
+// openPrecompressed 按客户端的Accept-Encoding打开预压缩文件，范围请求总是使用原文件
+func openPrecompressed(c *Context, fsys http.FileSystem, name string) (string, http.File, os.FileInfo) {
+	if c.Req.Header.Get("Range") != "" {
+		return "", nil, nil
+	}
+	accept := c.Req.Header.Get("Accept-Encoding")
+	supported := precompressedEncodings
+	for len(supported) > 0 {
+		encoding := negotiateEncoding(accept, supported)
+		if encoding == "" {
+			break
+		}
+		if f, err := fsys.Open(name + precompressedExt[encoding]); err == nil {
+			if info, err := f.Stat(); err == nil && !info.IsDir() {
+				return encoding, f, info
+			}
+			f.Close()
+		}
+		// 该编码没有预压缩文件，尝试下一个
+		rest := make([]string, 0, len(supported)-1)
+		for _, e := range supported {
+			if e != encoding {
+				rest = append(rest, e)
+			}
+		}
+		supported = rest
+	}
+	return "", nil, nil
+}
@@ -0,0 +1,99 @@
+package gee
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "empty"), 0o755)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0o644)
+	os.WriteFile(filepath.Join(dir, "docs", "index.html"), []byte("<h1>docs</h1>"), 0o644)
+
+	r := New()
+	r.Static("/browse", dir)
+	r.StaticWithConfig("/assets", StaticConfig{Root: dir, ETag: true, CacheControl: "public, max-age=60"})
+	r.StaticFile("/favicon.js", filepath.Join(dir, "app.js"))
+
+	w := performRequest(r, http.MethodGet, "/assets/app.js")
+	etag := w.Header().Get("ETag")
+	if w.Body.String() != "console.log(1)" || etag == "" || w.Header().Get("Cache-Control") != "public, max-age=60" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+		t.Fatalf("unexpected static response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if w = performRequest(r, http.MethodGet, "/assets/app.js", withHeader("If-None-Match", etag)); w.Code != http.StatusNotModified {
+		t.Fatalf("matching ETag should get 304, got %d", w.Code)
+	}
+	if w = performRequest(r, http.MethodGet, "/assets/docs/"); w.Body.String() != "<h1>docs</h1>" {
+		t.Fatalf("directory should serve index.html, got %d %q", w.Code, w.Body.String())
+	}
+	if w = performRequest(r, http.MethodGet, "/assets/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/assets/docs/" {
+		t.Fatalf("directory without slash should redirect, got %d %v", w.Code, w.Header())
+	}
+	// 关闭目录列表时返回404，Static默认允许列出
+	if w = performRequest(r, http.MethodGet, "/assets/empty/"); w.Code != http.StatusNotFound {
+		t.Fatalf("listing should be disabled, got %d", w.Code)
+	}
+	if w = performRequest(r, http.MethodGet, "/browse/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "app.js") {
+		t.Fatalf("listing should be enabled, got %d %q", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/assets/missing.js", "/assets/../gee.go"} {
+		if w = performRequest(r, http.MethodGet, path); w.Code != http.StatusNotFound {
+			t.Fatalf("%s should get 404, got %d", path, w.Code)
+		}
+	}
+	if w = performRequest(r, http.MethodGet, "/favicon.js"); w.Body.String() != "console.log(1)" {
+		t.Fatalf("unexpected StaticFile response %d %q", w.Code, w.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodHead, "/assets/app.js", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("Content-Length") != "14" {
+		t.Fatalf("unexpected HEAD response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestStaticFSAndSPA(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("body{}"))
+	zw.Close()
+	// fstest.MapFS与embed.FS一样实现了fs.FS
+	fsys := fstest.MapFS{
+		"index.html":     {Data: []byte("<div id=app></div>")},
+		"css/app.css":    {Data: []byte("body{}")},
+		"css/app.css.gz": {Data: gz.Bytes()},
+	}
+	r := New()
+	r.StaticWithConfig("/", StaticConfig{FS: fsys, Precompressed: true, SPAFallback: "index.html"})
+
+	w := performRequest(r, http.MethodGet, "/css/app.css", withHeader("Accept-Encoding", "br, gzip"))
+	if w.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(w.Body.Bytes(), gz.Bytes()) ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected gzip sidecar, got %v", w.Header())
+	}
+	if w = performRequest(r, http.MethodGet, "/css/app.css"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != "body{}" {
+		t.Fatalf("client without gzip should get the original file, got %v", w.Header())
+	}
+	if w = performRequest(r, http.MethodGet, "/users/42"); w.Code != http.StatusOK || w.Body.String() != "<div id=app></div>" {
+		t.Fatalf("SPA route should fall back to index.html, got %d %q", w.Code, w.Body.String())
+	}
+	if w = performRequest(r, http.MethodGet, "/css/missing.css"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing asset should get 404, got %d", w.Code)
+	}
+
+	r2 := New()
+	r2.StaticFS("/files", http.FS(fsys))
+	if w = performRequest(r2, http.MethodGet, "/files/css/app.css"); w.Body.String() != "body{}" {
+		t.Fatalf("unexpected StaticFS response %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,109 @@
+package gee
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Stream 反复调用step并在每次调用后flush，直到step返回false或客户端断开
+// 返回值表示客户端是否已经断开；流式响应会清除服务器的写超时，避免长连接被WriteTimeout中断
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	clearWriteDeadline(c.Writer)
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+		keepOpen := step(c.Writer)
+		c.Writer.Flush()
+		if !keepOpen {
+			return false
+		}
+	}
+}
+
+// clearWriteDeadline 底层不支持时忽略，例如httptest.ResponseRecorder
+func clearWriteDeadline(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// setSSEHeaders 响应头只在第一个事件之前设置
+func (c *Context) setSSEHeaders() {
+	if c.Writer.Written() {
+		return
+	}
+	header := c.Writer.Header()
+	header.Set("Content-Type", MIMEEventStream)
+	header.Set("Cache-Control", "no-cache")
+	// 关闭nginx等反向代理的缓冲
+	header.Set("X-Accel-Buffering", "no")
+}
+
+// SSEvent 发送一个事件并立即flush
+func (c *Context) SSEvent(name string, data interface{}) {
+	_ = c.WriteSSEvent(SSEvent{Event: name, Data: data})
+}
+
+// WriteSSEvent 发送一个完整的事件并立即flush，编码失败或客户端已断开时返回错误
+func (c *Context) WriteSSEvent(e SSEvent) error {
+	c.setSSEHeaders()
+	if err := e.Render(c.Writer); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return c.Req.Context().Err()
+}
+
+// LastEventID 客户端重连时带上的最后一个事件ID，用于从断点继续推送
+// 原生EventSource使用Last-Event-ID请求头，部分polyfill使用lastEventId查询参数
+func (c *Context) LastEventID() string {
+	if id := c.Req.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query("lastEventId")
+}
+
+// StreamSSE 依次发送events中的事件，直到channel关闭、事件编码失败或客户端断开，返回客户端是否已经断开
+// heartbeat大于0时，空闲超过该时间会发送一行注释，防止代理因空闲断开连接
+func (c *Context) StreamSSE(events <-chan SSEvent, heartbeat time.Duration) bool {
+	clearWriteDeadline(c.Writer)
+	c.setSSEHeaders()
+	// 先发出响应头，客户端可以立即确认连接已建立
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	var (
+		ticker *time.Ticker
+		tick   <-chan time.Time
+	)
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			if err := c.WriteSSEvent(e); err != nil {
+				return c.Req.Context().Err() != nil
+			}
+			if ticker != nil {
+				ticker.Reset(heartbeat)
+			}
+		case <-tick:
+			if _, err := c.Writer.WriteString(": heartbeat\n\n"); err != nil {
+				return true
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gee
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSSEvent 读取一个以空行结尾的事件
+func readSSEvent(t *testing.T, br *bufio.Reader) string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		if line == "\n" {
+			return strings.Join(lines, "")
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestSSEStream(t *testing.T) {
+	r := New()
+	r.Use(LoggerWithWriter(io.Discard), RecoveryWithWriter(io.Discard))
+	next := make(chan struct{})
+	gone := make(chan bool, 1)
+	r.GET("/events", func(c *Context) {
+		events := make(chan SSEvent)
+		go func() {
+			// 从Last-Event-ID之后继续推送
+			start := c.LastEventID()
+			events <- SSEvent{ID: start + "1", Event: "progress", Data: H{"percent": 50}}
+			<-next
+			events <- SSEvent{ID: start + "2", Event: "progress", Data: "done"}
+		}()
+		gone <- c.StreamSSE(events, 20*time.Millisecond)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "7-")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != MIMEEventStream || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+	br := bufio.NewReader(resp.Body)
+	// 第二个事件还没有产生，第一个事件已经到达客户端，说明中间件没有缓冲响应
+	if ev := readSSEvent(t, br); ev != "id: 7-1\nevent: progress\ndata: {\"percent\":50}\n" {
+		t.Fatalf("unexpected first event %q", ev)
+	}
+	if ev := readSSEvent(t, br); ev != ": heartbeat\n" {
+		t.Fatalf("expected heartbeat, got %q", ev)
+	}
+	close(next)
+	for {
+		ev := readSSEvent(t, br)
+		if ev == ": heartbeat\n" {
+			continue
+		}
+		if ev != "id: 7-2\nevent: progress\ndata: done\n" {
+			t.Fatalf("unexpected second event %q", ev)
+		}
+		break
+	}
+	// 客户端断开后处理函数应当返回
+	cancel()
+	select {
+	case clientGone := <-gone:
+		if !clientGone {
+			t.Fatal("stream should report the client as gone")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+}
+
+func TestStream(t *testing.T) {
+	r := New()
+	r.GET("/stream", func(c *Context) {
+		i := 0
+		c.Stream(func(w io.Writer) bool {
+			i++
+			io.WriteString(w, "chunk\n")
+			return i < 3
+		})
+	})
+	w := performRequest(r, http.MethodGet, "/stream")
+	if w.Body.String() != "chunk\nchunk\nchunk\n" || !w.Flushed {
+		t.Fatalf("unexpected stream body %q", w.Body.String())
+	}
+
+	r.GET("/sse", func(c *Context) {
+		c.SSEvent("message", "line1\nline2")
+	})
+	w = performRequest(r, http.MethodGet, "/sse?lastEventId=3")
+	if w.Body.String() != "event: message\ndata: line1\ndata: line2\n\n" || w.Header().Get("Content-Type") != MIMEEventStream {
+		t.Fatalf("unexpected SSEvent response %q %v", w.Body.String(), w.Header())
+	}
+}
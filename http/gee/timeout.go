@@ -0,0 +1,238 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig 处理超时配置
+type TimeoutConfig struct {
+	Timeout time.Duration
+	// 超时的状态码，默认503，网关类服务可以使用504
+	StatusCode int
+	// 自定义超时响应，设置后不再使用StatusCode
+	Handler HandlerFunc
+}
+
+// Timeout 使用默认超时响应的超时中间件
+func Timeout(timeout time.Duration) HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: timeout})
+}
+
+// TimeoutWithConfig 超时中间件，与rpc服务端的HandleTimeout一致：后续处理链在单独的goroutine中运行，
+// 请求context在超时后被取消，超时响应只写一次，处理函数之后的写入都会被丢弃
+// 处理函数的响应先写入缓冲，完成后才发出，因此不适合流式响应
+func TimeoutWithConfig(conf TimeoutConfig) HandlerFunc {
+	if conf.Timeout <= 0 {
+		panic("gee: timeout must be positive")
+	}
+	if conf.StatusCode == 0 {
+		conf.StatusCode = http.StatusServiceUnavailable
+	}
+	handle := conf.Handler
+	if handle == nil {
+		message := fmt.Sprintf("request handle timeout: expect within %s", conf.Timeout)
+		handle = func(c *Context) {
+			c.Fail(conf.StatusCode, message)
+		}
+	}
+
+	return func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), conf.Timeout)
+		defer cancel()
+
+		// 后续处理链使用独立的Context，超时返回后原Context被回收复用也不会与之竞争
+		tw := newTimeoutWriter(ctx, c.Writer)
+		tc := c.Copy()
+		tc.Req = c.Req.WithContext(ctx)
+		tc.Writer = tw
+		tc.handlers = c.handlers
+		tc.index = c.index
+
+		finished := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					tw.mu.Lock()
+					timedOut := tw.expired()
+					tw.mu.Unlock()
+					if timedOut {
+						// 已经返回了超时响应，只能记录下来
+						log.Printf("[Timeout] panic after timeout: %v %s %s", p, tc.Method, tc.Path)
+						return
+					}
+					panicked <- p
+				}
+			}()
+			tc.Next()
+			close(finished)
+		}()
+
+		select {
+		case p := <-panicked:
+			// 交给外层的Recovery处理
+			panic(p)
+		case <-finished:
+			tw.mu.Lock()
+			// 超时与完成同时发生时，处理函数的部分写入可能已被拒绝，按超时处理
+			if tw.expired() {
+				tw.mu.Unlock()
+				c.Abort()
+				handle(c)
+				return
+			}
+			defer tw.mu.Unlock()
+			tw.flushTo(c.Writer)
+			c.index = tc.index
+			c.principal = tc.principal
+			tc.mu.RLock()
+			for k, v := range tc.keys {
+				c.Set(k, v)
+			}
+			tc.mu.RUnlock()
+		case <-ctx.Done():
+			tw.mu.Lock()
+			tw.timedOut = true
+			tw.mu.Unlock()
+			c.Abort()
+			// 客户端断开时context同样结束，此时不再写超时响应
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				handle(c)
+			}
+		}
+	}
+}
+
+var errTimeoutUnsupported = errors.New("gee: not supported by the timeout middleware")
+
+// timeoutWriter 缓冲处理函数的响应，超时后拒绝所有写入
+type timeoutWriter struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	header http.Header
+	// 构造时取得的断开通知，不保留原始的ResponseWriter，超时返回后它会随Context被回收复用
+	closeNotify <-chan bool
+	buf         bytes.Buffer
+	status      int
+	written     bool
+	timedOut    bool
+}
+
+var _ ResponseWriter = &timeoutWriter{}
+
+func newTimeoutWriter(ctx context.Context, w ResponseWriter) *timeoutWriter {
+	// 外层中间件已设置的响应头同样可以被处理函数修改
+	return &timeoutWriter{ctx: ctx, header: w.Header().Clone(), closeNotify: w.CloseNotify(), status: defaultStatus}
+}
+
+// expired 处理函数可能先于中间件观察到超时，此时同样视为超时，调用时需持有mu
+// 客户端断开导致的取消不算超时
+func (tw *timeoutWriter) expired() bool {
+	if !tw.timedOut && errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		tw.timedOut = true
+	}
+	return tw.timedOut
+}
+
+// flushTo 处理函数按时完成后，把响应头和缓冲的响应体写到原始的ResponseWriter
+func (tw *timeoutWriter) flushTo(w ResponseWriter) {
+	dst := w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	w.WriteHeader(tw.status)
+	// 有响应体时直接写入，外层的Compress等中间件可以按内容决定是否压缩
+	if tw.buf.Len() > 0 {
+		_, _ = w.Write(tw.buf.Bytes())
+	} else if tw.written {
+		w.WriteHeaderNow()
+	}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() || tw.written || code <= 0 {
+		return
+	}
+	tw.status = code
+}
+
+func (tw *timeoutWriter) WriteHeaderNow() {
+	tw.mu.Lock()
+	tw.written = true
+	tw.mu.Unlock()
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.written = true
+	return tw.buf.Write(data)
+}
+
+func (tw *timeoutWriter) WriteString(s string) (int, error) {
+	return tw.Write([]byte(s))
+}
+
+func (tw *timeoutWriter) Status() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.status
+}
+
+func (tw *timeoutWriter) Size() int {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.written {
+		return noWritten
+	}
+	return tw.buf.Len()
+}
+
+func (tw *timeoutWriter) Written() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.written
+}
+
+// Flush 响应在处理函数完成后才发出，这里什么也不做
+func (tw *timeoutWriter) Flush() {}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errTimeoutUnsupported
+}
+
+func (tw *timeoutWriter) Push(string, *http.PushOptions) error {
+	return errTimeoutUnsupported
+}
+
+func (tw *timeoutWriter) CloseNotify() <-chan bool {
+	return tw.closeNotify
+}
+
+// Unwrap 返回nil，避免通过http.ResponseController绕过缓冲直接写响应
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return nil
+}
@@ -0,0 +1,142 @@
+package gee
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Outer", "1")
+		c.Next()
+	})
+	api := r.Group("/api")
+	api.Use(Timeout(50 * time.Millisecond))
+	api.GET("/fast", func(c *Context) {
+		c.SetHeader("X-Handler", "fast")
+		c.Set("handled", true)
+		c.String(http.StatusCreated, "done")
+	})
+	lateWrite := make(chan error, 1)
+	api.GET("/slow", func(c *Context) {
+		<-c.Done()
+		// 超时之后的写入被丢弃
+		_, err := c.Writer.WriteString("late")
+		lateWrite <- err
+	})
+
+	w := performRequest(r, http.MethodGet, "/api/fast")
+	if w.Code != http.StatusCreated || w.Body.String() != "done" ||
+		w.Header().Get("X-Handler") != "fast" || w.Header().Get("X-Outer") != "1" {
+		t.Fatalf("unexpected fast response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = performRequest(r, http.MethodGet, "/api/slow")
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "request handle timeout") {
+		t.Fatalf("unexpected timeout response %d %q", w.Code, w.Body.String())
+	}
+	if err := <-lateWrite; err != http.ErrHandlerTimeout {
+		t.Fatalf("late write should fail with ErrHandlerTimeout, got %v", err)
+	}
+	if strings.Contains(w.Body.String(), "late") {
+		t.Fatalf("late write leaked into response %q", w.Body.String())
+	}
+}
+
+func TestTimeoutCustomResponse(t *testing.T) {
+	r := New()
+	r.Use(TimeoutWithConfig(TimeoutConfig{Timeout: 10 * time.Millisecond, StatusCode: http.StatusGatewayTimeout}))
+	r.GET("/slow", func(c *Context) { time.Sleep(50 * time.Millisecond) })
+	if w := performRequest(r, http.MethodGet, "/slow"); w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", w.Code)
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	r := New()
+	r.Use(RecoveryWithWriter(&strings.Builder{}), Timeout(time.Second))
+	r.GET("/panic", func(c *Context) { panic("boom") })
+	if w := performRequest(r, http.MethodGet, "/panic"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("panic inside timeout should reach Recovery, got %d", w.Code)
+	}
+}
+
+func TestTimeoutClientCanceled(t *testing.T) {
+	r := New()
+	started := make(chan struct{})
+	r.GET("/wait", Timeout(time.Second), func(c *Context) {
+		close(started)
+		<-c.Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/wait", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	go func() {
+		<-started
+		cancel()
+	}()
+	r.ServeHTTP(w, req)
+	// 客户端断开不是超时，不应写出503
+	if w.Code == http.StatusServiceUnavailable || strings.Contains(w.Body.String(), "timeout") {
+		t.Fatalf("canceled request should not get a timeout response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutWithCompress(t *testing.T) {
+	body := strings.Repeat("gee timeout ", 400)
+	r := New()
+	r.Use(Compress(CompressConfig{}), Timeout(time.Second))
+	r.GET("/large", func(c *Context) {
+		c.String(http.StatusOK, body)
+	})
+
+	w := performRequest(r, http.MethodGet, "/large", withHeader("Accept-Encoding", "gzip"))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != EncodingGzip {
+		t.Fatalf("buffered response should still be compressed, got %d %v", w.Code, w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Fatalf("unexpected decompressed body %q", got)
+	}
+}
+
+func TestTimeoutHandlerOutlivesDeadline(t *testing.T) {
+	r := New()
+	var handlers sync.WaitGroup
+	r.GET("/slow", Timeout(2*time.Millisecond), func(c *Context) {
+		defer handlers.Done()
+		<-c.Done()
+		// 超时返回后原Context会被回收，后续请求复用它时处理函数仍在运行
+		time.Sleep(5 * time.Millisecond)
+		c.Writer.CloseNotify()
+	})
+
+	const n, rounds = 50, 3
+	handlers.Add(n * rounds)
+	var clients sync.WaitGroup
+	for i := 0; i < n; i++ {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for j := 0; j < rounds; j++ {
+				if w := performRequest(r, http.MethodGet, "/slow"); w.Code != http.StatusServiceUnavailable {
+					t.Errorf("want 503, got %d", w.Code)
+				}
+			}
+		}()
+	}
+	clients.Wait()
+	handlers.Wait()
+}
@@ -0,0 +1,200 @@
+package gee
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 节点类型，同时也是匹配优先级：静态段 > 参数 > 通配
+const (
+	staticPart = iota
+	paramPart
+	catchAllPart
+)
+
+// Param 一个路径参数，Value直接引用请求路径，不额外分配内存
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params 按出现顺序保存的路径参数，可在多个请求间复用
+type Params []Param
+
+// Get 返回参数值以及该参数是否存在
+func (ps Params) Get(name string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == name {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName 返回参数值，不存在时为空字符串
+func (ps Params) ByName(name string) string {
+	v, _ := ps.Get(name)
+	return v
+}
+
+// node 压缩前缀树(radix tree)节点
+// 静态节点的path可以跨越多个路径段，参数和通配节点的path是完整的一段，如":id"、"*filePath"
+// pattern字段有值的节点才是真正的路由节点
+type node struct {
+	pattern string
+	path    string
+	kind    int
+	// 参数名，去掉了':'或'*'前缀
+	key string
+	// 静态子节点及其首字节，indices[i]对应children[i]
+	indices  string
+	children []*node
+	// 参数和通配子节点只会挂在以'/'结尾的静态节点上
+	paramChild    *node
+	catchAllChild *node
+	// 路由节点上注册的处理链
+	handlers []HandlerFunc
+	// 自动应答OPTIONS请求时使用的处理链，由该路径所属分组的中间件加上默认应答组成
+	optionsHandlers []HandlerFunc
+}
+
+// insert 新增路由，pattern需已规范化，返回最终的路由节点
+// 静态节点分裂时保留原节点作为后缀，因此已返回的路由节点不会失效
+func (n *node) insert(pattern string, handlers []HandlerFunc) *node {
+	cur, path := n, pattern
+walk:
+	for {
+		if path == "" {
+			if cur.pattern != "" {
+				panic(fmt.Sprintf("gee: route '%s' conflicts with already registered route '%s'", pattern, cur.pattern))
+			}
+			cur.pattern = pattern
+			cur.handlers = handlers
+			return cur
+		}
+		if strings.HasSuffix(cur.path, "/") && cur.kind == staticPart && (path[0] == ':' || path[0] == '*') {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			part := path[:end]
+			child := &cur.paramChild
+			kind := paramPart
+			if part[0] == '*' {
+				child, kind = &cur.catchAllChild, catchAllPart
+			}
+			if *child == nil {
+				*child = &node{path: part, kind: kind, key: part[1:]}
+			} else if (*child).path != part {
+				panic(fmt.Sprintf("gee: wildcard '%s' in route '%s' conflicts with existing wildcard '%s'",
+					part, pattern, (*child).path))
+			}
+			cur, path = *child, path[end:]
+			continue
+		}
+		for i := 0; i < len(cur.indices); i++ {
+			if cur.indices[i] != path[0] {
+				continue
+			}
+			child := cur.children[i]
+			l := commonPrefix(path, child.path)
+			if l < len(child.path) {
+				// 分裂：新建公共前缀节点，原节点保留后缀
+				mid := &node{
+					path:     child.path[:l],
+					indices:  child.path[l : l+1],
+					children: []*node{child},
+				}
+				child.path = child.path[l:]
+				cur.children[i] = mid
+				child = mid
+			}
+			cur, path = child, path[l:]
+			continue walk
+		}
+		// 没有可共用前缀的静态子节点，新增一个，截止到下一个通配段
+		end := nextWildcard(path)
+		child := &node{path: path[:end]}
+		cur.indices += path[:1]
+		cur.children = append(cur.children, child)
+		cur, path = child, path[end:]
+	}
+}
+
+// search 在当前节点已匹配的前提下匹配剩余路径，参数追加到params中
+// 依次尝试静态子节点、参数、通配，失败时回溯并撤销已追加的参数，全程不分配内存
+func (n *node) search(path string, params *Params) *node {
+	if path == "" {
+		if n.pattern != "" {
+			return n
+		}
+		return nil
+	}
+	c := path[0]
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] != c {
+			continue
+		}
+		child := n.children[i]
+		if len(path) >= len(child.path) && path[:len(child.path)] == child.path {
+			if result := child.search(path[len(child.path):], params); result != nil {
+				return result
+			}
+		}
+		break
+	}
+	if child := n.paramChild; child != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			*params = append(*params, Param{Key: child.key, Value: path[:end]})
+			if result := child.search(path[end:], params); result != nil {
+				return result
+			}
+			*params = (*params)[:len(*params)-1]
+		}
+	}
+	if child := n.catchAllChild; child != nil && child.pattern != "" {
+		if child.key != "" {
+			*params = append(*params, Param{Key: child.key, Value: path})
+		}
+		return child
+	}
+	return nil
+}
+
+// travel 按优先级顺序收集所有路由节点
+func (n *node) travel(list *[]*node) {
+	if n.pattern != "" {
+		*list = append(*list, n)
+	}
+	for _, child := range n.children {
+		child.travel(list)
+	}
+	if n.paramChild != nil {
+		n.paramChild.travel(list)
+	}
+	if n.catchAllChild != nil {
+		n.catchAllChild.travel(list)
+	}
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// nextWildcard 返回path中下一个通配段的起始位置，通配符只在'/'之后才生效
+func nextWildcard(path string) int {
+	for i := 1; i < len(path); i++ {
+		if (path[i] == ':' || path[i] == '*') && path[i-1] == '/' {
+			return i
+		}
+	}
+	return len(path)
+}
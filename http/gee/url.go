@@ -0,0 +1,98 @@
+package gee
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RouteRef 注册路由的返回值，用于给路由命名，如r.GET("/user/:id", h).Name("user")
+type RouteRef struct {
+	engine *Engine
+	routes []*route
+}
+
+func (g *RouterGroup) newRouteRef(rt *route) *RouteRef {
+	return &RouteRef{engine: g.engine, routes: []*route{rt}}
+}
+
+// Name 为路由命名，供Engine.URL和模板函数url生成地址
+// 同一个名字只能对应一个路径，不同请求方法的同一路径可以共用名字
+func (r *RouteRef) Name(name string) *RouteRef {
+	if name == "" {
+		panic("gee: route name must not be empty")
+	}
+	e := r.engine
+	if e.namedRoutes == nil {
+		e.namedRoutes = make(map[string]string)
+	}
+	pattern := r.routes[0].node.pattern
+	if old, ok := e.namedRoutes[name]; ok && old != pattern {
+		panic(fmt.Sprintf("gee: route name %q is already used by %s", name, old))
+	}
+	e.namedRoutes[name] = pattern
+	for _, rt := range r.routes {
+		rt.name = name
+	}
+	return r
+}
+
+// URL 按路由名生成路径，params依次填充pattern中的":param"和"*wildcard"段，值会被转义
+// 路由按解码后的路径匹配，转义后的'/'同样会被当作分隔符，因此":param"的值不能包含'/'
+// 路由名不存在、参数个数不符、参数为空或":param"的值包含'/'时返回错误
+func (e *Engine) URL(name string, params ...interface{}) (string, error) {
+	pattern, ok := e.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("gee: route %q is not defined", name)
+	}
+	parts := parsePattern(pattern)
+	var b strings.Builder
+	i := 0
+	for _, part := range parts {
+		b.WriteByte('/')
+		if part[0] != ':' && part[0] != '*' {
+			b.WriteString(part)
+			continue
+		}
+		if i >= len(params) {
+			return "", fmt.Errorf("gee: route %q requires a value for %s", name, part)
+		}
+		value := fmt.Sprint(params[i])
+		i++
+		if part[0] == '*' {
+			// 通配段不匹配空路径，开头的'/'与pattern中的重复
+			value = strings.TrimPrefix(value, "/")
+		}
+		if value == "" {
+			return "", fmt.Errorf("gee: route %q got an empty value for %s", name, part)
+		}
+		if part[0] == ':' {
+			if strings.Contains(value, "/") {
+				return "", fmt.Errorf("gee: route %q got %q for %s, which can not contain '/'", name, value, part)
+			}
+			b.WriteString(url.PathEscape(value))
+			continue
+		}
+		segments := strings.Split(value, "/")
+		for j, seg := range segments {
+			segments[j] = url.PathEscape(seg)
+		}
+		b.WriteString(strings.Join(segments, "/"))
+	}
+	if i != len(params) {
+		return "", fmt.Errorf("gee: route %q takes %d parameters, got %d", name, i, len(params))
+	}
+	if b.Len() == 0 {
+		return "/", nil
+	}
+	return b.String(), nil
+}
+
+// MustURL 与URL相同，出错时panic，用于路由名和参数确定无误的场合
+func (e *Engine) MustURL(name string, params ...interface{}) string {
+	u, err := e.URL(name, params...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return u
+}
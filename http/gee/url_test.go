@@ -0,0 +1,95 @@
+package gee
+
+import (
+	"html/template"
+	"net/http"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEngineURL(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {}).Name("home")
+	v1 := r.Group("/api/v1")
+	v1.GET("/users/:id/posts/:post", func(c *Context) {}).Name("post")
+	v1.GET("/files/*filepath", func(c *Context) {}).Name("file")
+	r.Any("/any/", func(c *Context) {}).Name("any")
+
+	for _, tt := range []struct {
+		name   string
+		params []interface{}
+		want   string
+	}{
+		{"home", nil, "/"},
+		{"post", []interface{}{42, "a b?c"}, "/api/v1/users/42/posts/a%20b%3Fc"},
+		{"file", []interface{}{"/css/my file.css"}, "/api/v1/files/css/my%20file.css"},
+		{"any", nil, "/any"},
+	} {
+		got, err := r.URL(tt.name, tt.params...)
+		if err != nil || got != tt.want {
+			t.Fatalf("URL(%q, %v) = %q, %v; want %q", tt.name, tt.params, got, err, tt.want)
+		}
+	}
+
+	// 生成的地址应能匹配回原路由并还原参数
+	var id, post, file string
+	r.GET("/api/v1/check/:id/:post", func(c *Context) { id, post = c.Param("id"), c.Param("post") }).Name("check")
+	r.GET("/api/v1/download/*filepath", func(c *Context) { file = c.Param("filepath") }).Name("download")
+	if w := performRequest(r, http.MethodGet, r.MustURL("check", "x?y z", "a#b%")); w.Code != http.StatusOK || id != "x?y z" || post != "a#b%" {
+		t.Fatalf("params should round-trip, got %d %q %q", w.Code, id, post)
+	}
+	if w := performRequest(r, http.MethodGet, r.MustURL("download", "docs/a b#1.txt")); w.Code != http.StatusOK || file != "docs/a b#1.txt" {
+		t.Fatalf("wildcard should round-trip, got %d %q", w.Code, file)
+	}
+
+	// 转义后的'/'同样会被当作分隔符，无法匹配回原路由
+	for _, params := range [][]interface{}{{1}, {1, 2, 3}, {1, ""}, {1, "a/b"}} {
+		if _, err := r.URL("post", params...); err == nil {
+			t.Fatalf("URL with params %v should fail", params)
+		}
+	}
+	if _, err := r.URL("missing"); err == nil {
+		t.Fatal("undefined route name should fail")
+	}
+
+	for _, info := range r.Routes() {
+		if info.Path == "/api/v1/users/:id/posts/:post" && info.Name != "post" {
+			t.Fatalf("Routes should report the route name, got %+v", info)
+		}
+	}
+}
+
+func TestRouteNameConflict(t *testing.T) {
+	r := New()
+	r.GET("/a", func(c *Context) {}).Name("a")
+	// 不同请求方法的同一路径可以共用名字
+	r.POST("/a", func(c *Context) {}).Name("a")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("reusing a name for another path should panic")
+		}
+	}()
+	r.GET("/b", func(c *Context) {}).Name("a")
+}
+
+func TestURLTemplateFunc(t *testing.T) {
+	r := New()
+	r.LoadHTMLFS(fstest.MapFS{
+		"link.tmpl":   {Data: []byte(`<a href="{{url "user" .}}">{{.}}</a>`)},
+		"broken.tmpl": {Data: []byte(`<a href="{{url "nobody"}}">x</a>`)},
+	}, "*.tmpl")
+	r.SetFuncMap(template.FuncMap{})
+	r.GET("/users/:name", func(c *Context) {}).Name("user")
+	r.GET("/link", func(c *Context) {
+		c.HTML(http.StatusOK, "link.tmpl", "a&b")
+	})
+	r.GET("/broken", func(c *Context) {
+		c.HTML(http.StatusOK, "broken.tmpl", nil)
+	})
+	if w := performRequest(r, http.MethodGet, "/link"); w.Body.String() != `<a href="/users/a&amp;b">a&amp;b</a>` {
+		t.Fatalf("unexpected link %q", w.Body.String())
+	}
+	if w := performRequest(r, http.MethodGet, "/broken"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("unknown route in template should fail with 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,228 @@
+package gee
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// FieldError 一个字段未通过binding标签校验的原因，可直接序列化为400响应
+type FieldError struct {
+	// 字段路径，如"Address.City"、"Items[0].Name"
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// ValidationErrors 一次校验中所有失败的字段
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, e := range ve {
+		msgs[i] = e.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+var (
+	// 解析后的binding标签，按标签原文缓存
+	rulesCache sync.Map
+	// 编译后的regexp规则，按表达式缓存
+	regexpCache sync.Map
+)
+
+// parseRules 解析形如"required,min=1,oneof=a b,regexp=^[a-z,]+$"的标签
+// regexp的表达式可能包含逗号，因此它必须是最后一条规则
+func parseRules(tag string) []rule {
+	if v, ok := rulesCache.Load(tag); ok {
+		return v.([]rule)
+	}
+	var rules []rule
+	for rest := tag; rest != ""; {
+		var item string
+		if strings.HasPrefix(rest, "regexp=") {
+			item, rest = rest, ""
+		} else if i := strings.IndexByte(rest, ','); i >= 0 {
+			item, rest = rest[:i], rest[i+1:]
+		} else {
+			item, rest = rest, ""
+		}
+		r := rule{name: strings.TrimSpace(item)}
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			r.name, r.param = strings.TrimSpace(item[:i]), item[i+1:]
+		}
+		switch r.name {
+		case "required", "omitempty":
+		case "min", "max", "len":
+			if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+				panic(fmt.Sprintf("gee: invalid param %q for validation rule %s", r.param, r.name))
+			}
+		case "oneof":
+		case "regexp":
+			regexpCache.LoadOrStore(r.param, regexp.MustCompile(r.param))
+		default:
+			panic(fmt.Sprintf("gee: unknown validation rule %q", r.name))
+		}
+		rules = append(rules, r)
+	}
+	rulesCache.Store(tag, rules)
+	return rules
+}
+
+// validate 按binding标签校验obj，嵌套结构体以及结构体切片中的元素会被递归校验
+func validate(obj interface{}) error {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(obj), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, ns string, errs *ValidationErrors) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() != timeType {
+			validateStruct(v, ns, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if indirectType(v.Type().Elem()).Kind() != reflect.Struct {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", ns, i), errs)
+		}
+	}
+}
+
+func validateStruct(v reflect.Value, ns string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		name := sf.Name
+		if sf.Anonymous {
+			name = ns
+		} else if ns != "" {
+			name = ns + "." + sf.Name
+		}
+		tag := sf.Tag.Get("binding")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" && !validateField(v.Field(i), name, parseRules(tag), errs) {
+			continue
+		}
+		validateValue(v.Field(i), name, errs)
+	}
+}
+
+// validateField 依次检查字段上的规则，遇到第一条失败的规则即停止，返回字段是否通过
+func validateField(fv reflect.Value, name string, rules []rule, errs *ValidationErrors) bool {
+	for _, r := range rules {
+		switch r.name {
+		case "omitempty":
+			if fv.IsZero() {
+				return true
+			}
+			continue
+		case "required":
+			if fv.IsZero() {
+				*errs = append(*errs, FieldError{Field: name, Tag: r.name, Message: name + " is required"})
+				return false
+			}
+			continue
+		}
+		v := fv
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				// 非必填的空指针不再检查其余规则
+				return true
+			}
+			v = v.Elem()
+		}
+		if msg := checkRule(v, r); msg != "" {
+			*errs = append(*errs, FieldError{Field: name, Tag: r.name, Param: r.param, Message: name + " " + msg})
+			return false
+		}
+	}
+	return true
+}
+
+// checkRule 返回规则失败时的说明，通过时返回空字符串
+func checkRule(v reflect.Value, r rule) string {
+	switch r.name {
+	case "min", "max", "len":
+		limit, _ := strconv.ParseFloat(r.param, 64)
+		size, isLength := sizeOf(v)
+		subject := "must be"
+		if isLength {
+			subject = "length must be"
+		}
+		switch {
+		case r.name == "min" && size < limit:
+			return fmt.Sprintf("%s at least %s", subject, r.param)
+		case r.name == "max" && size > limit:
+			return fmt.Sprintf("%s at most %s", subject, r.param)
+		case r.name == "len" && size != limit:
+			return fmt.Sprintf("%s %s", subject, r.param)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(r.param) {
+			if s == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", r.param)
+	case "regexp":
+		if v.Kind() != reflect.String {
+			panic(fmt.Sprintf("gee: validation rule regexp does not support type %s", v.Type()))
+		}
+		re, _ := regexpCache.Load(r.param)
+		if !re.(*regexp.Regexp).MatchString(v.String()) {
+			return fmt.Sprintf("must match %s", r.param)
+		}
+	}
+	return ""
+}
+
+// sizeOf 数字类型返回其值，字符串返回字符数，容器返回元素个数
+func sizeOf(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	}
+	panic(fmt.Sprintf("gee: size validation does not support type %s", v.Type()))
+}
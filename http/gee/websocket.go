@@ -0,0 +1,508 @@
+package gee
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket消息类型，取值与RFC 6455的opcode一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket关闭码，见RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	wsContinuation = 0
+	wsFinalBit     = 0x80
+	wsRSVBits      = 0x70
+	wsMaskBit      = 0x80
+	// 控制帧的负载不能超过125字节
+	wsMaxControlPayload = 125
+	wsAcceptGUID        = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// 默认的单条消息大小上限
+	defaultWebSocketReadLimit = 1 << 20
+)
+
+// CloseError 对端发送了关闭帧，或者因协议错误由本端关闭了连接
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("gee: websocket closed: %d %s", e.Code, e.Text)
+}
+
+// ErrWebSocketClosed 连接已经关闭后继续读写
+var ErrWebSocketClosed = errors.New("gee: websocket connection is closed")
+
+// WebSocketConfig WebSocket升级配置
+type WebSocketConfig struct {
+	// 单条消息(包括所有分片)的最大字节数，超出时以1009关闭连接，默认1MB
+	ReadLimit int64
+	// 服务端支持的子协议，按客户端给出的顺序选择第一个双方都支持的
+	Subprotocols []string
+	// 校验Origin，默认只允许没有Origin或与Host同源的请求
+	// 已经用CORS中间件校验过来源时，可以设为始终返回true
+	CheckOrigin func(r *http.Request) bool
+	// 握手响应的写超时，0表示不限制
+	HandshakeTimeout time.Duration
+}
+
+// WebSocketConn 一条WebSocket连接，同一时刻只能有一个goroutine读，写可以并发
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	readLimit   int64
+	subprotocol string
+
+	// 写帧时持有，保证帧不会交错
+	wmu    sync.Mutex
+	bw     *bufio.Writer
+	closed bool
+	// 已经发出关闭帧
+	closeSent bool
+
+	pongHandler func(data string) error
+	pingHandler func(data string) error
+}
+
+// IsWebSocket 请求是否为WebSocket升级请求
+func (c *Context) IsWebSocket() bool {
+	return headerContainsToken(c.Req.Header, "Connection", "upgrade") &&
+		headerContainsToken(c.Req.Header, "Upgrade", "websocket")
+}
+
+// UpgradeWebSocket 完成握手并接管连接，之后不能再通过Context写响应
+// 握手失败时已写好错误响应并中止处理链，调用方直接返回即可
+// 认证、CORS等中间件在升级之前照常执行，被它们中止的请求不会走到这里
+func (c *Context) UpgradeWebSocket(conf WebSocketConfig) (*WebSocketConn, error) {
+	fail := func(code int, msg string) (*WebSocketConn, error) {
+		c.Fail(code, msg)
+		return nil, errors.New("gee: websocket handshake failed: " + msg)
+	}
+	if c.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "websocket upgrade requires GET")
+	}
+	if !c.IsWebSocket() {
+		return fail(http.StatusBadRequest, "not a websocket upgrade request")
+	}
+	if c.Req.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := c.Req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := conf.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(c.Req) {
+		return fail(http.StatusForbidden, "websocket origin not allowed")
+	}
+	subprotocol := selectSubprotocol(c.Req, conf.Subprotocols)
+
+	// 先记下101，访问日志中可以看到升级后的状态码
+	c.Status(http.StatusSwitchingProtocols)
+	conn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	c.Abort()
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		resp.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+	if conf.HandshakeTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(conf.HandshakeTimeout))
+	}
+	bw := bufio.NewWriter(conn)
+	if _, err = bw.WriteString(resp.String()); err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if conf.HandshakeTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Time{})
+	}
+
+	readLimit := conf.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultWebSocketReadLimit
+	}
+	return &WebSocketConn{
+		conn:        conn,
+		br:          brw.Reader,
+		bw:          bw,
+		readLimit:   readLimit,
+		subprotocol: subprotocol,
+	}, nil
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(value, ",") {
+			p = strings.TrimSpace(p)
+			for _, s := range supported {
+				if p == s {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// headerContainsToken 判断逗号分隔的请求头中是否包含token，不区分大小写
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Subprotocol 协商出的子协议，没有时为空
+func (ws *WebSocketConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+func (ws *WebSocketConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+func (ws *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+func (ws *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler 收到ping时调用，默认回复同样内容的pong
+func (ws *WebSocketConn) SetPingHandler(h func(data string) error) {
+	ws.pingHandler = h
+}
+
+// SetPongHandler 收到pong时调用，通常用来延长读超时
+func (ws *WebSocketConn) SetPongHandler(h func(data string) error) {
+	ws.pongHandler = h
+}
+
+// ReadMessage 读取下一条完整的消息，分片会被拼接，期间收到的控制帧在这里处理
+// 对端关闭时返回*CloseError，协议错误时按对应的关闭码关闭连接并返回*CloseError
+func (ws *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	messageType = -1
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return -1, nil, ws.fail(err)
+		}
+		switch opcode {
+		case PingMessage:
+			if ws.pingHandler != nil {
+				err = ws.pingHandler(string(payload))
+			} else {
+				err = ws.WriteControl(PongMessage, payload)
+			}
+			if err != nil {
+				return -1, nil, err
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				if err = ws.pongHandler(string(payload)); err != nil {
+					return -1, nil, err
+				}
+			}
+			continue
+		case CloseMessage:
+			return -1, nil, ws.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != -1 {
+				return -1, nil, ws.fail(&CloseError{Code: CloseProtocolError, Text: "expected continuation frame"})
+			}
+			messageType = opcode
+		case wsContinuation:
+			if messageType == -1 {
+				return -1, nil, ws.fail(&CloseError{Code: CloseProtocolError, Text: "unexpected continuation frame"})
+			}
+		default:
+			return -1, nil, ws.fail(&CloseError{Code: CloseProtocolError, Text: fmt.Sprintf("unknown opcode %d", opcode)})
+		}
+		if int64(len(data)+len(payload)) > ws.readLimit {
+			return -1, nil, ws.fail(&CloseError{Code: CloseMessageTooBig, Text: "message too big"})
+		}
+		data = append(data, payload...)
+		if fin {
+			break
+		}
+	}
+	if messageType == TextMessage && !utf8.Valid(data) {
+		return -1, nil, ws.fail(&CloseError{Code: CloseInvalidFramePayloadData, Text: "invalid UTF-8 in text message"})
+	}
+	return messageType, data, nil
+}
+
+// readFrame 读取并解除掩码一个帧，客户端发来的帧必须带掩码
+func (ws *WebSocketConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(ws.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&wsFinalBit != 0
+	opcode = int(head[0] & 0x0f)
+	if head[0]&wsRSVBits != 0 {
+		err = &CloseError{Code: CloseProtocolError, Text: "unexpected reserved bits"}
+		return
+	}
+	if head[1]&wsMaskBit == 0 {
+		err = &CloseError{Code: CloseProtocolError, Text: "client frame is not masked"}
+		return
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(ws.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= CloseMessage && (length > wsMaxControlPayload || !fin) {
+		err = &CloseError{Code: CloseProtocolError, Text: "invalid control frame"}
+		return
+	}
+	// 单个帧就超出上限时不读取负载，避免分配过大的内存
+	if length > uint64(ws.readLimit) {
+		err = &CloseError{Code: CloseMessageTooBig, Text: "message too big"}
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+	maskBytes(mask, payload)
+	return
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i&3]
+	}
+}
+
+// handleClose 回复对端的关闭帧并关闭连接
+func (ws *WebSocketConn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return ws.fail(&CloseError{Code: CloseProtocolError, Text: "invalid close payload"})
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Text = string(payload[2:])
+		if !validCloseCode(ce.Code) || !utf8.Valid(payload[2:]) {
+			return ws.fail(&CloseError{Code: CloseProtocolError, Text: "invalid close frame"})
+		}
+	}
+	reply := ce.Code
+	if reply == CloseNoStatusReceived {
+		reply = CloseNormalClosure
+	}
+	_ = ws.writeClose(reply, "")
+	ws.closeConn()
+	return ce
+}
+
+// validCloseCode 对端可以在关闭帧中发送的关闭码
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail 读取出错时关闭连接，协议错误会先发送对应的关闭帧
+func (ws *WebSocketConn) fail(err error) error {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		_ = ws.writeClose(ce.Code, ce.Text)
+	}
+	ws.closeConn()
+	return err
+}
+
+// WriteMessage 以单个帧发送一条文本或二进制消息，服务端发出的帧不带掩码
+func (ws *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("gee: invalid websocket message type %d", messageType)
+	}
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	return ws.writeFrame(messageType, data)
+}
+
+// WriteControl 发送ping、pong等控制帧，负载不能超过125字节
+func (ws *WebSocketConn) WriteControl(messageType int, data []byte) error {
+	if messageType < CloseMessage || len(data) > wsMaxControlPayload {
+		return fmt.Errorf("gee: invalid websocket control frame")
+	}
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	return ws.writeFrame(messageType, data)
+}
+
+// Ping 发送ping，对端回复的pong交给SetPongHandler设置的函数处理
+func (ws *WebSocketConn) Ping(data []byte) error {
+	return ws.WriteControl(PingMessage, data)
+}
+
+func (ws *WebSocketConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, data)
+}
+
+func (ws *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (ws *WebSocketConn) writeFrame(opcode int, data []byte) error {
+	if ws.closed || (ws.closeSent && opcode != CloseMessage) {
+		return ErrWebSocketClosed
+	}
+	var head [10]byte
+	head[0] = wsFinalBit | byte(opcode)
+	n := 2
+	switch {
+	case len(data) <= 125:
+		head[1] = byte(len(data))
+	case len(data) <= 0xffff:
+		head[1] = 126
+		binary.BigEndian.PutUint16(head[2:], uint16(len(data)))
+		n = 4
+	default:
+		head[1] = 127
+		binary.BigEndian.PutUint64(head[2:], uint64(len(data)))
+		n = 10
+	}
+	if _, err := ws.bw.Write(head[:n]); err != nil {
+		return err
+	}
+	if _, err := ws.bw.Write(data); err != nil {
+		return err
+	}
+	return ws.bw.Flush()
+}
+
+// writeClose 发送关闭帧，只会发送一次
+func (ws *WebSocketConn) writeClose(code int, text string) error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	if ws.closeSent || ws.closed {
+		return nil
+	}
+	ws.closeSent = true
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	if len(payload) > wsMaxControlPayload {
+		payload = payload[:wsMaxControlPayload]
+	}
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return ws.writeFrame(CloseMessage, payload)
+}
+
+// Close 发送关闭帧后关闭底层连接，不等待对端的回复
+func (ws *WebSocketConn) Close(code int, reason string) error {
+	err := ws.writeClose(code, reason)
+	if cerr := ws.closeConn(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (ws *WebSocketConn) closeConn() error {
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	return ws.conn.Close()
+}
@@ -0,0 +1,222 @@
+package gee
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient 测试用的最小客户端，发出的帧都带掩码
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string, header ...string) (*wsClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key [16]byte
+	rand.Read(key[:])
+	req := "GET " + path + " HTTP/1.1\r\nHost: " + srv.Listener.Addr().String() +
+		"\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: " + base64.StdEncoding.EncodeToString(key[:]) + "\r\n"
+	for i := 0; i+1 < len(header); i += 2 {
+		req += header[i] + ": " + header[i+1] + "\r\n"
+	}
+	conn.Write([]byte(req + "\r\n"))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols &&
+		resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(base64.StdEncoding.EncodeToString(key[:])) {
+		t.Fatalf("unexpected Sec-WebSocket-Accept %q", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &wsClient{conn: conn, br: br}, resp
+}
+
+func (cl *wsClient) writeFrame(fin bool, opcode int, payload []byte) {
+	head := []byte{byte(opcode), wsMaskBit}
+	if fin {
+		head[0] |= wsFinalBit
+	}
+	switch {
+	case len(payload) <= 125:
+		head[1] |= byte(len(payload))
+	case len(payload) <= 0xffff:
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(len(payload)))
+	default:
+		head[1] |= 127
+		head = binary.BigEndian.AppendUint64(head, uint64(len(payload)))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	masked := append([]byte(nil), payload...)
+	maskBytes(mask, masked)
+	cl.conn.Write(append(append(head, mask[:]...), masked...))
+}
+
+func (cl *wsClient) readFrame(t *testing.T) (int, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(cl.br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[1]&wsMaskBit != 0 {
+		t.Fatal("server frames must not be masked")
+	}
+	length := int(head[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(cl.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	io.ReadFull(cl.br, payload)
+	return int(head[0] & 0x0f), payload
+}
+
+func closePayload(code int, text string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), text...)
+}
+
+func newWebSocketServer(t *testing.T, conf WebSocketConfig, serverErr chan<- error) *httptest.Server {
+	r := New()
+	r.Use(BasicAuth(Accounts{"u": "p"}))
+	r.GET("/ws", func(c *Context) {
+		ws, err := c.UpgradeWebSocket(conf)
+		if err != nil {
+			return
+		}
+		defer ws.Close(CloseNormalClosure, "")
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				serverErr <- err
+				return
+			}
+			ws.WriteMessage(mt, append([]byte("echo:"), data...))
+		}
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const wsAuth = "Basic dTpw" // u:p
+
+func TestWebSocketEcho(t *testing.T) {
+	serverErr := make(chan error, 1)
+	srv := newWebSocketServer(t, WebSocketConfig{Subprotocols: []string{"chat"}}, serverErr)
+
+	// 升级前仍经过认证中间件
+	if _, resp := dialWebSocket(t, srv, "/ws"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated upgrade should get 401, got %d", resp.StatusCode)
+	}
+
+	cl, resp := dialWebSocket(t, srv, "/ws", "Authorization", wsAuth, "Sec-WebSocket-Protocol", "v2, chat")
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Protocol") != "chat" {
+		t.Fatalf("unexpected handshake %d %v", resp.StatusCode, resp.Header)
+	}
+
+	cl.writeFrame(true, TextMessage, []byte("hi"))
+	if op, data := cl.readFrame(t); op != TextMessage || string(data) != "echo:hi" {
+		t.Fatalf("unexpected echo %d %q", op, data)
+	}
+
+	// 分片消息中间夹着ping，先收到pong，再收到拼接后的消息
+	cl.writeFrame(false, BinaryMessage, []byte("frag"))
+	cl.writeFrame(true, PingMessage, []byte("p1"))
+	cl.writeFrame(true, wsContinuation, []byte(strings.Repeat("x", 300)))
+	if op, data := cl.readFrame(t); op != PongMessage || string(data) != "p1" {
+		t.Fatalf("expected pong, got %d %q", op, data)
+	}
+	if op, data := cl.readFrame(t); op != BinaryMessage || string(data) != "echo:frag"+strings.Repeat("x", 300) {
+		t.Fatalf("unexpected fragmented echo %d %d bytes", op, len(data))
+	}
+
+	cl.writeFrame(true, CloseMessage, closePayload(CloseGoingAway, "bye"))
+	if op, data := cl.readFrame(t); op != CloseMessage || binary.BigEndian.Uint16(data) != CloseGoingAway {
+		t.Fatalf("expected close reply, got %d %v", op, data)
+	}
+	var ce *CloseError
+	if err := <-serverErr; !errors.As(err, &ce) || ce.Code != CloseGoingAway || ce.Text != "bye" {
+		t.Fatalf("unexpected server error %v", err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	serverErr := make(chan error, 1)
+	srv := newWebSocketServer(t, WebSocketConfig{ReadLimit: 16}, serverErr)
+
+	cases := []struct {
+		name string
+		send func(cl *wsClient)
+		code int
+	}{
+		{"too big", func(cl *wsClient) {
+			cl.writeFrame(false, TextMessage, []byte("0123456789"))
+			cl.writeFrame(true, wsContinuation, []byte("0123456789"))
+		}, CloseMessageTooBig},
+		{"invalid utf8", func(cl *wsClient) { cl.writeFrame(true, TextMessage, []byte{0xff, 0xfe}) }, CloseInvalidFramePayloadData},
+		{"orphan continuation", func(cl *wsClient) { cl.writeFrame(true, wsContinuation, []byte("x")) }, CloseProtocolError},
+		{"unmasked", func(cl *wsClient) { cl.conn.Write([]byte{wsFinalBit | TextMessage, 1, 'x'}) }, CloseProtocolError},
+		{"fragmented control", func(cl *wsClient) { cl.writeFrame(false, PingMessage, nil) }, CloseProtocolError},
+	}
+	for _, tc := range cases {
+		cl, resp := dialWebSocket(t, srv, "/ws", "Authorization", wsAuth)
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("%s: handshake failed with %d", tc.name, resp.StatusCode)
+		}
+		tc.send(cl)
+		if op, data := cl.readFrame(t); op != CloseMessage || int(binary.BigEndian.Uint16(data)) != tc.code {
+			t.Fatalf("%s: expected close %d, got %d %v", tc.name, tc.code, op, data)
+		}
+		<-serverErr
+		cl.conn.Close()
+	}
+}
+
+func TestWebSocketHandshakeErrors(t *testing.T) {
+	r := New()
+	r.GET("/ws", func(c *Context) { c.UpgradeWebSocket(WebSocketConfig{}) })
+	request := func(header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		req.Host = "example.com"
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	upgrade := []string{"Connection", "Upgrade", "Upgrade", "websocket", "Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ=="}
+
+	if w := request(); w.Code != http.StatusBadRequest {
+		t.Fatalf("plain request should get 400, got %d", w.Code)
+	}
+	if w := request(append(upgrade, "Sec-WebSocket-Version", "8")...); w.Code != http.StatusUpgradeRequired ||
+		w.Header().Get("Sec-WebSocket-Version") != "13" {
+		t.Fatalf("old version should get 426, got %d", w.Code)
+	}
+	if w := request(append(upgrade, "Sec-WebSocket-Version", "13", "Origin", "https://evil.com")...); w.Code != http.StatusForbidden {
+		t.Fatalf("cross origin should get 403, got %d", w.Code)
+	}
+	if websocketAccept("dGhlIHNhbXBsZSBub25jZQ==") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("accept key does not match RFC 6455 example")
+	}
+}
@@ -1,3 +1,10 @@
-module http_learn
-
-go 1.17
+module http_learn
+
+go 1.21
+
+require (
+	google.golang.org/protobuf v1.28.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/andybalholm/brotli v1.0.5
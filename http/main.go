@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"html/template"
-	"http/http/gee"
+	"http_learn/gee"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -69,5 +74,18 @@ func main() {
 		c.String(http.StatusOK, names[100])
 	})
 
-	r.RUN(":8080")
+	go func() {
+		if err := r.Run(":8080"); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	// 收到退出信号后等待正在处理的请求结束
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Shutdown(ctx); err != nil {
+		log.Println("shutdown:", err)
+	}
 }